@@ -0,0 +1,330 @@
+package ivy
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tr1v3r/pkg/guard"
+	"github.com/tr1v3r/pkg/log"
+)
+
+// TreeBuilderE tree build method reporting error
+type TreeBuilderE func() (Tree, error)
+
+// TreeReport is the result of the latest build of a tree.
+type TreeReport struct {
+	// Name is the tree name, or "builder#<id>" for an unnamed builder never built successfully
+	Name string
+	// At is when the build started
+	At       time.Time
+	Duration time.Duration
+	// Err is nil when the build succeeded
+	Err error
+
+	// Failures counts consecutive failed builds, reset on success
+	Failures int
+	// LastSuccess is when the tree was built successfully last time
+	LastSuccess time.Time
+}
+
+// BuildReport is the results of latest builds of all trees in forest.
+type BuildReport struct {
+	// Trees sorted by name
+	Trees []TreeReport
+}
+
+// Failed return reports of trees whose latest build failed
+func (r *BuildReport) Failed() (failed []TreeReport) {
+	for _, tree := range r.Trees {
+		if tree.Err != nil {
+			failed = append(failed, tree)
+		}
+	}
+	return failed
+}
+
+// OK check if latest builds of all trees succeeded
+func (r *BuildReport) OK() bool { return len(r.Failed()) == 0 }
+
+// Report returns results of latest builds of all trees, by Build, RefreshTree or schedule.
+func (f *forest) Report() *BuildReport {
+	f.repMu.RLock()
+	defer f.repMu.RUnlock()
+
+	var report BuildReport
+	for _, tree := range f.reports {
+		report.Trees = append(report.Trees, *tree)
+	}
+	sort.Slice(report.Trees, func(i, j int) bool { return report.Trees[i].Name < report.Trees[j].Name })
+	return &report
+}
+
+// report record result of build started at start and took d
+func (f *forest) report(name string, start time.Time, d time.Duration, err error) {
+	if err != nil {
+		log.Error("build tree %s fail: %s", name, err)
+	}
+
+	f.repMu.Lock()
+	defer f.repMu.Unlock()
+	if f.reports == nil {
+		f.reports = make(map[string]*TreeReport)
+	}
+	r := f.reports[name]
+	if r == nil {
+		r = &TreeReport{Name: name}
+		f.reports[name] = r
+	}
+	r.At, r.Duration, r.Err = start, d, err
+	if err != nil {
+		r.Failures++
+	} else {
+		r.Failures, r.LastSuccess = 0, start
+	}
+}
+
+// BuildOptions controls how Build runs builders.
+type BuildOptions struct {
+	// Concurrency bounds builders running at the same time,
+	// zero or negative means runtime.GOMAXPROCS(0)
+	Concurrency int
+	// Timeout bounds the whole build, zero means no limit.
+	// Builders not done in time are reported as timeout and their trees keep the
+	// last version, until the builds are done and published by themselves.
+	Timeout time.Duration
+}
+
+// SetBuildOptions sets how Build runs builders.
+func (f *forest) SetBuildOptions(opts BuildOptions) {
+	f.bMu.Lock()
+	defer f.bMu.Unlock()
+	f.opts = opts
+}
+
+func (f *forest) buildOptions() BuildOptions {
+	f.bMu.RLock()
+	defer f.bMu.RUnlock()
+	return f.opts
+}
+
+// buildResult result of a builder run
+type buildResult struct {
+	tree Tree
+	err  error
+	// joined is true when a build already running was joined, which publishes its own result
+	joined bool
+	// seq orders builds by start, results older than the one published are dropped
+	seq uint64
+
+	at       time.Time
+	duration time.Duration
+}
+
+// buildAll run builders in a worker pool bounded by build options,
+// and publish results in the order of builders.
+func (f *forest) buildAll(builders []*builder) {
+	opts := f.buildOptions()
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+	}
+	defer cancel()
+
+	start := time.Now()
+	sem := make(chan struct{}, concurrency)
+	slots := make([]*buildSlot, len(builders))
+	for i, b := range builders {
+		slots[i] = newBuildSlot()
+		go func(b *builder, s *buildSlot) {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			var once sync.Once
+			release := func() { once.Do(func() { <-sem }) }
+			defer release()
+			if ctx.Err() != nil {
+				return
+			}
+			// builder is released before waiting to be published in order
+			if !b.run(func(res buildResult) { release(); s.deliver(f, b, res) }) {
+				release()
+				s.deliver(f, b, buildResult{joined: true})
+			}
+		}(b, slots[i])
+	}
+
+	for i, s := range slots {
+		var res buildResult
+		select {
+		case res = <-s.ready:
+		case <-ctx.Done():
+			var ok bool
+			if res, ok = s.abandon(); !ok {
+				f.report(builders[i].key(nil), start, time.Since(start), fmt.Errorf("build tree timeout: %w", ctx.Err()))
+				continue
+			}
+		}
+		if !res.joined {
+			f.publish(builders[i], res)
+		}
+		close(s.published)
+	}
+}
+
+// buildSlot hands result of a build over to Build to be published in order.
+// once Build stops waiting for it, the build publishes its result by itself.
+type buildSlot struct {
+	mu        sync.Mutex
+	abandoned bool
+
+	ready     chan buildResult
+	published chan struct{}
+}
+
+func newBuildSlot() *buildSlot {
+	return &buildSlot{ready: make(chan buildResult, 1), published: make(chan struct{})}
+}
+
+// deliver hand res over and wait until it is published, or publish it if abandoned.
+// results published by themselves are checked like any other, so a build finished after
+// its tree removed or rebuilt by a newer build is dropped.
+func (s *buildSlot) deliver(f *forest, b *builder, res buildResult) {
+	s.mu.Lock()
+	if s.abandoned {
+		s.mu.Unlock()
+		if !res.joined {
+			f.publish(b, res)
+		}
+		return
+	}
+	s.ready <- res
+	s.mu.Unlock()
+	<-s.published
+}
+
+// abandon stop waiting for the build, return its result if already delivered
+func (s *buildSlot) abandon() (buildResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case res := <-s.ready:
+		return res, true
+	default:
+		s.abandoned = true
+		return buildResult{}, false
+	}
+}
+
+// builder is a registered TreeBuilder, concurrent builds of it are coalesced into one.
+type builder struct {
+	build TreeBuilderE
+	id    uint64
+	// fixed is true when registered by name, trees of other names are rejected
+	fixed bool
+
+	mu   sync.Mutex
+	name string // name registered, or of the tree built last time
+	call *buildCall
+	// pubMu serializes publishing results, so they are published in the order built
+	pubMu sync.Mutex
+}
+
+// builderID generates builder id, buildSeq sequence of builds
+var builderID, buildSeq uint64
+
+// buildCall a running build, done is closed after its result is published
+type buildCall struct {
+	done chan struct{}
+}
+
+func newBuilder(build TreeBuilder) *builder {
+	return newBuilderE(func() (Tree, error) {
+		if tree := build(); tree != nil {
+			return tree, nil
+		}
+		return nil, ErrNilTree
+	})
+}
+
+func newBuilderE(build TreeBuilderE) *builder {
+	return &builder{build: build, id: atomic.AddUint64(&builderID, 1)}
+}
+
+// named fix the name of trees built
+func (b *builder) named(name string) *builder {
+	b.name, b.fixed = name, true
+	return b
+}
+
+// run build tree and publish the result, or wait for the running build to be published.
+// leader is true only for the caller actually built the tree.
+func (b *builder) run(publish func(buildResult)) (leader bool) {
+	b.mu.Lock()
+	if call := b.call; call != nil {
+		b.mu.Unlock()
+		<-call.done
+		return false
+	}
+	call := &buildCall{done: make(chan struct{})}
+	b.call = call
+	b.mu.Unlock()
+
+	seq, start := atomic.AddUint64(&buildSeq, 1), time.Now()
+	tree, err := b.safeBuild()
+	res := buildResult{tree: tree, err: err, seq: seq, at: start, duration: time.Since(start)}
+
+	// builds started from now on are published after this one
+	b.pubMu.Lock()
+	defer b.pubMu.Unlock()
+	defer close(call.done)
+
+	b.mu.Lock()
+	b.call = nil
+	if err == nil {
+		b.name = tree.Name()
+	}
+	b.mu.Unlock()
+
+	publish(res)
+	return true
+}
+
+func (b *builder) safeBuild() (tree Tree, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			tree, err = nil, fmt.Errorf("build tree panic: %v, stack: %s", e, guard.CatchStack())
+		}
+	}()
+	switch tree, err = b.build(); {
+	case err != nil:
+	case tree == nil:
+		err = ErrNilTree
+	case b.fixed && tree.Name() != b.name:
+		tree, err = nil, fmt.Errorf("builder registered as %s builds tree %s", b.name, tree.Name())
+	}
+	return tree, err
+}
+
+// key return name to report build result by
+func (b *builder) key(tree Tree) string {
+	if tree != nil {
+		return tree.Name()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.name != "" {
+		return b.name
+	}
+	return fmt.Sprintf("builder#%d", b.id)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"time"
@@ -39,14 +40,19 @@ import (
 var timeout, _ = time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT"))
 
 func main() {
-	web.InitForest(web.DefaultBuilder(load()...))
+	forest, err := initForest()
+	if err != nil {
+		log.Fatal("init forest fail: %s", err)
+	}
+	web.SetForest(forest)
 
-	go func() {
-		for range time.Tick(5 * time.Second) {
-			log.Info("refreshing forest...")
-			web.RefreshForest()
-		}
-	}()
+	stopLogging := logChanges(forest)
+	defer stopLogging()
+
+	if err := forest.Start(context.Background()); err != nil {
+		log.Fatal("start forest fail: %s", err)
+	}
+	defer forest.Stop()
 
 	if timeout == 0 {
 		timeout = 3 * time.Second
@@ -54,6 +60,23 @@ func main() {
 	web.Serve(timeout, register(gin.Default()))
 }
 
+// logChanges logs changes of all trees in forest, including those added later,
+// until the returned function called
+func logChanges(forest ivy.Forest) (stop func()) {
+	events, stop := forest.Watch("", "")
+	go func() {
+		for e := range events {
+			if len(e.Patch) == 0 {
+				log.Info("tree %s version %d: %s %s", e.Tree, e.Version, e.Kind, e.Path)
+				continue
+			}
+			patch, _ := json.Marshal(e.Patch)
+			log.Info("tree %s version %d: %s %s: %s", e.Tree, e.Version, e.Kind, e.Path, patch)
+		}
+	}()
+	return stop
+}
+
 func register(r *gin.Engine) *gin.Engine {
 	apiV1 := r.Group("api/v1")
 	{
@@ -65,7 +88,32 @@ func register(r *gin.Engine) *gin.Engine {
 	return r
 }
 
-var defaultFilename = "../../conf/rules.json"
+var (
+	defaultForestFilename = "../../conf/forest.json"
+
+	// defaultRefresh is the refresh interval of trees from rules file
+	defaultRefresh = 5 * time.Second
+)
+
+// initForest build forest from spec file FOREST_FILE,
+// falls back to the single default tree from rules file when RULES_FILE is set.
+func initForest() (ivy.Forest, error) {
+	if os.Getenv("RULES_FILE") != "" {
+		forest := ivy.NewForest(web.DefaultBuilder(load()...))
+		forest.SetDefaultSchedule(ivy.Schedule{Interval: defaultRefresh})
+		return forest, nil
+	}
+
+	var filename = os.Getenv("FOREST_FILE")
+	if filename == "" {
+		filename = defaultForestFilename
+	}
+	spec, err := ivy.LoadForestSpec(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ivy.LoadForest(spec)
+}
 
 type RuleDataItem struct {
 	Path       string `json:"path"`
@@ -75,12 +123,9 @@ type RuleDataItem struct {
 	} `json:"Processors"`
 }
 
+// load directives from rules file RULES_FILE
 func load() (directives []ivy.Directive) {
-	var filename = os.Getenv("RULES_FILE")
-	if filename == "" {
-		filename = defaultFilename
-	}
-	data, err := os.ReadFile(filename)
+	data, err := os.ReadFile(os.Getenv("RULES_FILE"))
 	if err != nil {
 		log.Error("read file fail: %s", err)
 		return nil
@@ -94,23 +139,13 @@ func load() (directives []ivy.Directive) {
 	for _, line := range items {
 		var ops []driver.Processor
 		for _, opData := range line.Processors {
-			var op driver.Processor
-			switch opData.Type {
-			case "json":
-				op = new(driver.JSONProcessor)
-			case "yaml":
-				op = new(driver.YAMLProcessor)
-			case "curl":
-				op = new(driver.CURLProcessor)
-			case "xml":
-				op = new(driver.XMLProcessor)
-			case "toml":
-				op = new(driver.TOMLProcessor)
+			op, err := driver.NewProcessor(opData.Type)
+			if err != nil {
+				log.Warn("create Process fail: %s", err)
+				continue
 			}
-			if op != nil {
-				if err := op.Load(opData.Data); err != nil {
-					log.Warn("load Process fail: %s\ndata: %s", err, opData.Data)
-				}
+			if err := op.Load(opData.Data); err != nil {
+				log.Warn("load Process fail: %s\ndata: %s", err, opData.Data)
 			}
 			ops = append(ops, op)
 		}
@@ -0,0 +1,208 @@
+package ivy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/tr1v3r/ivy/driver"
+)
+
+// DiffKind kind of node difference
+type DiffKind string
+
+const (
+	// NodeAdded node exists only in new tree
+	NodeAdded DiffKind = "added"
+	// NodeRemoved node exists only in old tree
+	NodeRemoved DiffKind = "removed"
+	// NodeChanged node exists in both trees with different content or directives
+	NodeChanged DiffKind = "changed"
+)
+
+// NodeDiff is the difference on a node between two trees.
+type NodeDiff struct {
+	Path string
+	Kind DiffKind
+
+	// Old and New are realized content of the node in old and new tree,
+	// nil if the node is lazy and was not realized
+	Old, New []byte
+	// Patch turns Old into New, only for changed nodes of json trees
+	Patch driver.JSONPatch
+}
+
+// TreeDiff is the difference between two trees, nodes are in depth-first order.
+type TreeDiff struct {
+	Nodes []NodeDiff
+}
+
+// Empty check if nothing changed
+func (d *TreeDiff) Empty() bool { return len(d.Nodes) == 0 }
+
+// String show one node difference per line
+func (d *TreeDiff) String() string {
+	var b strings.Builder
+	for _, node := range d.Nodes {
+		fmt.Fprintf(&b, "%s %s", node.Kind, node.Path)
+		if len(node.Patch) > 0 {
+			patch, _ := json.Marshal(node.Patch)
+			fmt.Fprintf(&b, ": %s", patch)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// DiffTrees walks node hierarchies of oldTree and newTree, reports nodes added and removed,
+// and nodes whose content differs. No node is realized for it: content is what each node
+// has realized, and changes on lazy nodes not realized are told by their directives, or by
+// a change on their parent, which they inherit content from.
+// Either can be nil, all nodes of the other tree are reported as added or removed.
+func DiffTrees(oldTree, newTree Tree) (*TreeDiff, error) {
+	x, err := asTree(oldTree)
+	if err != nil {
+		return nil, err
+	}
+	y, err := asTree(newTree)
+	if err != nil {
+		return nil, err
+	}
+
+	var diff TreeDiff
+	if err := diff.walk(x, y, false); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// walk compare node x in old tree with node y in new tree and their subtrees,
+// parentChanged is true if parent of the nodes is reported changed
+func (d *TreeDiff) walk(x, y *tree, parentChanged bool) (err error) {
+	var node NodeDiff
+	var changed bool
+	switch {
+	case x == nil && y == nil:
+		return nil
+	case x == nil:
+		node = NodeDiff{Path: y.nodePath(), Kind: NodeAdded}
+		node.New, _ = y.storedContent()
+		changed = true
+	case y == nil:
+		node = NodeDiff{Path: x.nodePath(), Kind: NodeRemoved}
+		node.Old, _ = x.storedContent()
+		changed = true
+	default:
+		node = NodeDiff{Path: y.nodePath(), Kind: NodeChanged}
+		var okX, okY bool
+		node.Old, okX = x.storedContent()
+		node.New, okY = y.storedContent()
+		if okX && okY {
+			changed = !bytes.Equal(node.Old, node.New)
+		} else {
+			changed = parentChanged || !sameDirectives(x.getDirectives(), y.getDirectives())
+		}
+		if changed && okX && okY && y.driver.Name() == "json" {
+			if node.Patch, err = driver.DiffJSON(node.Old, node.New); err != nil {
+				return fmt.Errorf("diff %s fail: %w", node.Path, err)
+			}
+		}
+	}
+	if changed {
+		d.Nodes = append(d.Nodes, node)
+	}
+
+	names, err := childNames(x, y)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		cx, _ := childOf(x, name).(*tree)
+		cy, _ := childOf(y, name).(*tree)
+		if err := d.walk(cx, cy, changed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameDirectives check if directives are the same, processors are compared by what they save,
+// processors saving nothing, like unnamed RawProcessor, are the same only if identical.
+func sameDirectives(x, y []Directive) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i].Path() != y[i].Path() {
+			return false
+		}
+		px, py := x[i].Processors(), y[i].Processors()
+		if len(px) != len(py) {
+			return false
+		}
+		for j := range px {
+			if !sameProcessor(px[j], py[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func sameProcessor(x, y driver.Processor) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	if t := reflect.TypeOf(x); t == reflect.TypeOf(y) && t.Comparable() && x == y {
+		return true
+	}
+	if x.Type() != y.Type() {
+		return false
+	}
+	a, b := x.Save(), y.Save()
+	return a != nil && b != nil && bytes.Equal(a, b)
+}
+
+// asTree convert Tree to *tree, nil is allowed
+func asTree(t Tree) (*tree, error) {
+	if t == nil {
+		return nil, nil
+	}
+	node, ok := t.(*tree)
+	if !ok {
+		return nil, fmt.Errorf("diff fail: unsupported tree type %T", t)
+	}
+	return node, nil
+}
+
+// childNames return sorted names of children of both nodes
+func childNames(nodes ...*tree) ([]string, error) {
+	var names []string
+	var seen = make(map[string]bool)
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		for _, child := range node.getChildren() {
+			if _, ok := child.(*tree); !ok {
+				return nil, fmt.Errorf("diff on %s fail: unsupported tree type %T", child.Path(), child)
+			}
+			if !seen[child.Name()] {
+				seen[child.Name()] = true
+				names = append(names, child.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func childOf(node *tree, name string) Tree {
+	if node == nil {
+		return nil
+	}
+	return node.pickChild(name)
+}
@@ -1,6 +1,7 @@
 package ivy
 
 import (
+	"fmt"
 	"sort"
 
 	"github.com/tr1v3r/ivy/driver"
@@ -17,6 +18,31 @@ type directive struct {
 func (d *directive) Path() string                   { return d.path }
 func (d *directive) Processors() []driver.Processor { return d.processors }
 
+// DirectiveOp is an operation on the directive list of a node.
+type DirectiveOp int
+
+const (
+	// OpReplace replaces the directive list of the node with the directive
+	OpReplace DirectiveOp = iota
+	// OpAppend appends the directive to the directive list of the node
+	OpAppend
+	// OpRemove clears the directive list of the node, the node is kept
+	OpRemove
+)
+
+func (op DirectiveOp) String() string {
+	switch op {
+	case OpReplace:
+		return "replace"
+	case OpAppend:
+		return "append"
+	case OpRemove:
+		return "remove"
+	default:
+		return fmt.Sprintf("DirectiveOp(%d)", int(op))
+	}
+}
+
 // directives is a sortable slice of Directive.
 type directives[R Directive] []R
 
@@ -34,9 +60,10 @@ func (s *sorter[R]) Less(i, j int) bool { return s.by(s.directives[i], s.directi
 // By is the type of a "less" function that defines the ordering of its Directive arguments.
 type by[R Directive] func(x, y R) bool
 
-// Sort sorts the argument slice according to the function.
+// Sort sorts the argument slice according to the function,
+// keeping the original order of equal elements.
 func (b by[R]) Sort(directives []R) {
-	sort.Sort(&sorter[R]{
+	sort.Stable(&sorter[R]{
 		directives: directives,
 		by:         b,
 	})
@@ -58,3 +58,30 @@ func (r *StdRealizer) Realize(rc *RealizeContext, rule []byte, procs ...Processo
 	}
 	return rule, nil
 }
+
+// SegmentKind kind of a path segment
+type SegmentKind int
+
+const (
+	// LiteralSegment matches the segment name exactly
+	LiteralSegment SegmentKind = iota
+	// ParamSegment matches any single segment, declared as ":name"
+	ParamSegment
+	// WildcardSegment matches all remaining segments, declared as "*" or "*name"
+	WildcardSegment
+)
+
+// ParseSegment parse segment kind and the param name it captures
+// wildcard segment without name captures into param "*"
+func ParseSegment(segment string) (kind SegmentKind, param string) {
+	switch {
+	case len(segment) > 1 && segment[0] == ':':
+		return ParamSegment, segment[1:]
+	case segment == "*":
+		return WildcardSegment, segment
+	case len(segment) > 1 && segment[0] == '*':
+		return WildcardSegment, segment[1:]
+	default:
+		return LiteralSegment, ""
+	}
+}
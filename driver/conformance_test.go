@@ -0,0 +1,151 @@
+package driver_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/tr1v3r/ivy/driver"
+)
+
+// conformance describes how a driver with path based processors is checked by the shared suite
+type conformance struct {
+	driver driver.Driver
+	// base has key "a" with a scalar and key "list" with an array of one element
+	base []byte
+	// value is a scalar in driver format
+	value []byte
+
+	// proc create processor of type on key
+	proc func(typ, key string, value []byte) driver.Processor
+	// count return 0 if key not exists, length of array, or 1 for other values
+	count func(t *testing.T, doc []byte, key string) int
+}
+
+func countValue(v any, ok bool) int {
+	switch v := v.(type) {
+	case []any:
+		return len(v)
+	default:
+		if !ok {
+			return 0
+		}
+		return 1
+	}
+}
+
+var conformances = map[string]conformance{
+	"json": {
+		driver: driver.NewJSONDriver(),
+		base:   []byte(`{"a":"x","list":["x"]}`),
+		value:  []byte("y"),
+		proc: func(typ, key string, value []byte) driver.Processor {
+			return &driver.JSONProcessor{T: typ, JSONPath: key, V: value, ValueType: "string"}
+		},
+		count: func(t *testing.T, doc []byte, key string) int {
+			var m map[string]any
+			if err := json.Unmarshal(doc, &m); err != nil {
+				t.Fatalf("unmarshal json fail: %s", err)
+			}
+			v, ok := m[key]
+			return countValue(v, ok)
+		},
+	},
+	"toml": {
+		driver: driver.NewTOMLDriver(),
+		base:   []byte("a = \"x\"\nlist = [\"x\"]\n"),
+		value:  []byte(`"y"`),
+		proc: func(typ, key string, value []byte) driver.Processor {
+			return &driver.TOMLProcessor{T: typ, TOMLPath: key, V: value}
+		},
+		count: func(t *testing.T, doc []byte, key string) int {
+			var m map[string]any
+			if err := toml.Unmarshal(doc, &m); err != nil {
+				t.Fatalf("unmarshal toml fail: %s", err)
+			}
+			v, ok := m[key]
+			return countValue(v, ok)
+		},
+	},
+	"yaml": {
+		driver: driver.NewYAMLDriver(),
+		base:   []byte("a: x\nlist: [x]\n"),
+		value:  []byte("y"),
+		proc: func(typ, key string, value []byte) driver.Processor {
+			return &driver.YAMLProcessor{T: typ, YAMLPath: key, V: value}
+		},
+		count: func(t *testing.T, doc []byte, key string) int {
+			var m map[string]any
+			if err := yaml.Unmarshal(doc, &m); err != nil {
+				t.Fatalf("unmarshal yaml fail: %s", err)
+			}
+			v, ok := m[key]
+			return countValue(v, ok)
+		},
+	},
+	"xml": { // arrays in xml are repeated elements
+		driver: driver.NewXMLDriver(),
+		base:   []byte("<root><a>x</a><list>x</list></root>"),
+		value:  []byte("y"),
+		proc: func(typ, key string, value []byte) driver.Processor {
+			return &driver.XMLProcessor{T: typ, XMLPath: "root/" + key, V: value}
+		},
+		count: func(_ *testing.T, doc []byte, key string) int {
+			return strings.Count(string(doc), "<"+key+">") + strings.Count(string(doc), "<"+key+"/>")
+		},
+	},
+}
+
+func TestConformance(t *testing.T) {
+	var testcases = []struct {
+		Type    string
+		Key     string
+		WantErr bool
+		Count   int
+	}{
+		{"create", "b", false, 1},
+		{"create", "a", true, 0},
+		{"set", "a", false, 1},
+		{"set", "b", false, 1},
+		{"replace", "a", false, 1},
+		{"replace", "b", true, 0},
+		{"append", "list", false, 2},
+		{"append", "b", false, 1},
+		{"delete", "a", false, 0},
+		{"delete", "b", true, 0},
+		{"unknown", "a", true, 0},
+	}
+
+	for name, c := range conformances {
+		for _, item := range testcases {
+			proc := c.proc(item.Type, item.Key, c.value)
+
+			// processor alone and through driver realizer must agree
+			processed, err := proc.Process(nil, c.base)
+			realized, rerr := c.driver.Realize(nil, c.base, proc)
+			if (err != nil) != (rerr != nil) {
+				t.Errorf("%s: %s %s: process error %v, realize error %v", name, item.Type, item.Key, err, rerr)
+				continue
+			}
+			if item.WantErr {
+				if err == nil {
+					t.Errorf("%s: %s %s: expected error, got: %s", name, item.Type, item.Key, processed)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: %s %s fail: %s", name, item.Type, item.Key, err)
+				continue
+			}
+			if string(processed) != string(realized) {
+				t.Errorf("%s: %s %s: process got %s, realize got %s", name, item.Type, item.Key, processed, realized)
+			}
+			if count := c.count(t, processed, item.Key); count != item.Count {
+				t.Errorf("%s: %s %s: expected count %d, got %d in: %s", name, item.Type, item.Key, item.Count, count, processed)
+			}
+		}
+	}
+}
@@ -39,6 +39,26 @@ func TestSlashPathParser(t *testing.T) {
 	}
 }
 
+func TestParseSegment(t *testing.T) {
+	var testcases = []struct {
+		segment string
+		kind    driver.SegmentKind
+		param   string
+	}{
+		{"users", driver.LiteralSegment, ""},
+		{":", driver.LiteralSegment, ""},
+		{":id", driver.ParamSegment, "id"},
+		{"*", driver.WildcardSegment, "*"},
+		{"*file", driver.WildcardSegment, "file"},
+	}
+
+	for _, item := range testcases {
+		if kind, param := driver.ParseSegment(item.segment); kind != item.kind || param != item.param {
+			t.Errorf("parse segment %s expected (%d, %s), got (%d, %s)", item.segment, item.kind, item.param, kind, param)
+		}
+	}
+}
+
 func TestDriver_Marshal(t *testing.T) {
 	var buf = make([]json.RawMessage, 0, 8)
 	for i := range [8]int{} {
@@ -64,8 +84,8 @@ func TestJSONDriver(t *testing.T) {
 	data, err := d.Marshal([]driver.Processor{
 		&driver.JSONProcessor{T: "create", JSONPath: "name.first", V: []byte("river")},
 		&driver.JSONProcessor{T: "create", JSONPath: "name.last", V: []byte("chu")},
-		&driver.JSONProcessor{T: "create", JSONPath: "name.last", V: []byte("Chu")},
-		&driver.JSONProcessor{T: "append", JSONPath: "dear.friends.-1", V: []byte("tom")},
+		&driver.JSONProcessor{T: "replace", JSONPath: "name.last", V: []byte("Chu")},
+		&driver.JSONProcessor{T: "append", JSONPath: "dear.friends", V: []byte("tom")},
 		&driver.JSONProcessor{T: "append", JSONPath: "dear.friends.-1", V: []byte("ken")},
 		&driver.JSONProcessor{T: "set", JSONPath: "dear.family", V: []byte(`["mom","dad","bro"]`)},
 		&driver.JSONProcessor{T: "create", JSONPath: "name.verbose", V: []byte("verbose")},
@@ -88,6 +108,124 @@ func TestJSONDriver(t *testing.T) {
 		}
 	}
 	t.Logf("got result: %s", rule)
+
+	expected := `{"name":{"first":"river","last":"Chu"},"dear":{"friends":["tom","ken"],"family":["mom","dad","bro"]}}`
+	if string(rule) != expected {
+		t.Errorf("expected %s, got %s", expected, rule)
+	}
+}
+
+func TestJSONProcessor_ValueType(t *testing.T) {
+	var testcases = []struct {
+		ValueType string
+		Value     string
+		Expected  string
+	}{
+		{"", "1", `{"v":"1"}`},
+		{"string", "true", `{"v":"true"}`},
+		{"number", "1.5", `{"v":1.5}`},
+		{"bool", "true", `{"v":true}`},
+		{"null", "", `{"v":null}`},
+		{"raw", `{"a":[1]}`, `{"v":{"a":[1]}}`},
+	}
+	for _, item := range testcases {
+		op := &driver.JSONProcessor{T: "create", JSONPath: "v", V: []byte(item.Value), ValueType: item.ValueType}
+		rule, err := op.Process(nil, []byte(`{}`))
+		if err != nil {
+			t.Errorf("Process %s fail: %s", item.ValueType, err)
+			continue
+		}
+		if string(rule) != item.Expected {
+			t.Errorf("expected %s, got %s", item.Expected, rule)
+		}
+	}
+
+	for _, data := range []string{
+		`{"type":"create","json_path":"v","value":"abc","value_type":"number"}`,
+		`{"type":"create","json_path":"v","value":1,"value_type":"bool"}`,
+		`{"type":"create","json_path":"v","value":"1","value_type":"int"}`,
+		`{"type":"create","json_path":"v","value":"not base64"}`,
+	} {
+		if err := new(driver.JSONProcessor).Load([]byte(data)); err == nil {
+			t.Errorf("expected load %s fail", data)
+		}
+	}
+}
+
+func TestJSONProcessor_Load(t *testing.T) {
+	var testcases = []struct {
+		Data     string
+		Expected string
+	}{
+		{`{"type":"create","json_path":"v","value":"MQ=="}`, `{"v":"1"}`}, // base64 without value_type
+		{`{"type":"create","json_path":"v","value":"1","value_type":"string"}`, `{"v":"1"}`},
+		{`{"type":"create","json_path":"v","value":"1.5","value_type":"number"}`, `{"v":1.5}`},
+		{`{"type":"create","json_path":"v","value":1.5,"value_type":"number"}`, `{"v":1.5}`},
+		{`{"type":"create","json_path":"v","value":1.5}`, `{"v":1.5}`},
+		{`{"type":"create","json_path":"v","value":{"a":[true]}}`, `{"v":{"a":[true]}}`},
+		{`{"type":"create","json_path":"v","value":null,"value_type":"null"}`, `{"v":null}`},
+	}
+	for _, item := range testcases {
+		op := new(driver.JSONProcessor)
+		if err := op.Load([]byte(item.Data)); err != nil {
+			t.Errorf("load %s fail: %s", item.Data, err)
+			continue
+		}
+
+		// saved processor loads the same
+		saved := new(driver.JSONProcessor)
+		if err := saved.Load(op.Save()); err != nil {
+			t.Errorf("load saved %s fail: %s", op.Save(), err)
+			continue
+		}
+		for _, op := range []*driver.JSONProcessor{op, saved} {
+			rule, err := op.Process(nil, []byte(`{}`))
+			if err != nil {
+				t.Errorf("Process %s fail: %s", item.Data, err)
+				continue
+			}
+			if string(rule) != item.Expected {
+				t.Errorf("%s: expected %s, got %s", op.Save(), item.Expected, rule)
+			}
+		}
+	}
+}
+
+func TestJSONProcessor_Compatible(t *testing.T) {
+	// saved before value_type existed, all values in base64
+	saved := []byte(`[
+		{"path":"","type":"create","json_path":"name","value":"cml2ZXI=","author":"river","created_at":"2024-01-02T03:04:05Z"},
+		{"path":"","type":"set","json_path":"tags","value":"WyJhIl0=","author":"river","created_at":"2024-01-02T03:04:05Z"},
+		{"path":"","type":"delete","json_path":"id","value":null,"author":"river","created_at":"2024-01-02T03:04:05Z"}
+	]`)
+	d := driver.NewJSONDriver()
+	procs, err := d.Unmarshal(saved)
+	if err != nil {
+		t.Fatalf("unmarshal saved processors fail: %s", err)
+	}
+	if len(procs) != 3 || procs[0].Author() != "river" || procs[0].CreatedAt().IsZero() {
+		t.Fatalf("expected saved processors loaded with metadata, got %+v", procs)
+	}
+
+	// saved again loads the same
+	resaved, err := d.Marshal(procs...)
+	if err != nil {
+		t.Fatalf("marshal processors fail: %s", err)
+	}
+	reloaded, err := d.Unmarshal(resaved)
+	if err != nil {
+		t.Fatalf("unmarshal resaved processors %s fail: %s", resaved, err)
+	}
+	for _, procs := range [][]driver.Processor{procs, reloaded} {
+		rule, err := d.Realize(nil, []byte(`{"id":1}`), procs...)
+		if err != nil {
+			t.Errorf("realize fail: %s", err)
+			continue
+		}
+		if expected := `{"name":"river","tags":["a"]}`; string(rule) != expected {
+			t.Errorf("expected %s, got %s", expected, rule)
+		}
+	}
 }
 
 func TestYAMLProcessor(t *testing.T) {
@@ -132,3 +270,243 @@ func TestTileDriver(t *testing.T) {
 	}
 	t.Logf("got result: %s", rule)
 }
+
+func TestRegistryModem(t *testing.T) {
+	d := driver.NewJSONDriver()
+
+	data, err := d.Marshal(
+		&driver.CURLProcessor{URL: "https://example.com/ping"},
+		&driver.JSONProcessor{T: "create", JSONPath: "name", V: []byte("river")},
+		&driver.JSONProcessor{T: "delete", JSONPath: "id"},
+	)
+	if err != nil {
+		t.Fatalf("marshal fail: %s", err)
+	}
+
+	ops, err := d.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unmarshal fail: %s", err)
+	}
+	if len(ops) != 3 {
+		t.Fatalf("expected 3 processors, got %d", len(ops))
+	}
+	if op, ok := ops[0].(*driver.CURLProcessor); !ok || op.URL != "https://example.com/ping" {
+		t.Errorf("expected curl processor, got %#v", ops[0])
+	}
+	if op, ok := ops[1].(*driver.JSONProcessor); !ok || op.T != "create" || string(op.V) != "river" {
+		t.Errorf("expected json create processor, got %#v", ops[1])
+	}
+	if op, ok := ops[2].(*driver.JSONProcessor); !ok || op.T != "delete" {
+		t.Errorf("expected json delete processor, got %#v", ops[2])
+	}
+
+	// items saved without kind fall back to driver default kind
+	ops, err = d.Unmarshal([]byte(`[{"type":"delete","json_path":"id"}]`))
+	if err != nil {
+		t.Fatalf("unmarshal legacy data fail: %s", err)
+	}
+	if _, ok := ops[0].(*driver.JSONProcessor); !ok {
+		t.Errorf("expected json processor for legacy data, got %#v", ops[0])
+	}
+}
+
+type upperProcessor struct{ driver.DummyProcessor }
+
+func (op *upperProcessor) Type() string      { return "upper" }
+func (op *upperProcessor) Save() []byte      { return []byte(`{}`) }
+func (op *upperProcessor) Load([]byte) error { return nil }
+
+func TestRegistry_Scoped(t *testing.T) {
+	registry := driver.NewRegistry(driver.DefaultRegistry)
+	registry.Register("upper", func() driver.Processor { return new(upperProcessor) })
+
+	m := &driver.RegistryModem{Registry: registry, Marshaler: json.Marshal, Unmarshaler: json.Unmarshal}
+	data, err := m.Marshal(new(upperProcessor), &driver.JSONProcessor{T: "delete", JSONPath: "id"})
+	if err != nil {
+		t.Fatalf("marshal fail: %s", err)
+	}
+	ops, err := m.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unmarshal fail: %s", err)
+	}
+	if _, ok := ops[0].(*upperProcessor); !ok {
+		t.Errorf("expected scoped processor, got %#v", ops[0])
+	}
+	if _, ok := ops[1].(*driver.JSONProcessor); !ok {
+		t.Errorf("expected global processor, got %#v", ops[1])
+	}
+
+	if _, err := driver.NewProcessor("upper"); err == nil {
+		t.Errorf("expected scoped kind not registered globally")
+	}
+}
+
+func TestCombinedProcessor_SaveLoad(t *testing.T) {
+	driver.RegisterRawFunc("test.stamp", func(_ *driver.RealizeContext, before []byte) ([]byte, error) {
+		return append(before[:len(before)-1:len(before)-1], `,"stamp":true}`...), nil
+	})
+	stamp, err := driver.NamedRawProcessor("test.stamp")
+	if err != nil {
+		t.Fatalf("create named raw processor fail: %s", err)
+	}
+
+	d := driver.NewJSONDriver()
+	data, err := d.Marshal(
+		driver.CombineProcessor(
+			&driver.JSONProcessor{T: "create", JSONPath: "name", V: []byte("river")},
+			driver.CombineProcessor(stamp),
+		),
+		&driver.JSONProcessor{T: "delete", JSONPath: "id"},
+	)
+	if err != nil {
+		t.Fatalf("marshal fail: %s", err)
+	}
+	t.Logf("got data: %s", data)
+
+	ops, err := d.Unmarshal(data)
+	if err != nil {
+		t.Fatalf("unmarshal fail: %s", err)
+	}
+	rule, err := d.Realize(nil, []byte(`{"id":1}`), ops...)
+	if err != nil {
+		t.Fatalf("realize fail: %s", err)
+	}
+	if expected := `{"name":"river","stamp":true}`; string(rule) != expected {
+		t.Errorf("expected %s, got %s", expected, rule)
+	}
+
+	if _, err := d.Marshal(&driver.RawProcessor{Proc: stamp.Proc}); err == nil {
+		t.Errorf("expected anonymous raw processor not serializable")
+	}
+	if _, err := d.Unmarshal([]byte(`[{"kind":"raw","data":{"name":"test.missing"}}]`)); err == nil {
+		t.Errorf("expected unregistered raw function fail to load")
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	var testcases = []struct {
+		before, after string
+		expected      string
+	}{
+		{`{"a":1}`, `{"a":1}`, `null`},
+		{`{"a":1,"b":{"c":"x"}}`, `{"a":2,"b":{"d":null}}`,
+			`[{"op":"replace","path":"/a","value":2},{"op":"remove","path":"/b/c"},{"op":"add","path":"/b/d","value":null}]`},
+		{`{"l":[1,2,3]}`, `{"l":[1,4]}`,
+			`[{"op":"replace","path":"/l/1","value":4},{"op":"remove","path":"/l/2"}]`},
+		{`{"l":[1]}`, `{"l":[1,{"k":true}]}`, `[{"op":"add","path":"/l/1","value":{"k":true}}]`},
+		{`{"a/b":1,"m~n":1}`, `{"a/b":"1"}`,
+			`[{"op":"remove","path":"/m~0n"},{"op":"replace","path":"/a~1b","value":"1"}]`},
+		{`{"a":1}`, `[1]`, `[{"op":"replace","path":"","value":[1]}]`},
+	}
+	for _, tc := range testcases {
+		patch, err := driver.DiffJSON([]byte(tc.before), []byte(tc.after))
+		if err != nil {
+			t.Fatalf("diff %s -> %s fail: %s", tc.before, tc.after, err)
+		}
+		if got, _ := json.Marshal(patch); string(got) != tc.expected {
+			t.Errorf("diff %s -> %s expected %s, got %s", tc.before, tc.after, tc.expected, got)
+		}
+	}
+}
+
+func TestJSONPatchProcessor(t *testing.T) {
+	var testcases = []struct {
+		Before   string
+		Patch    string
+		Expected string // empty if patch should fail
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"foo":"bar","baz":"qux"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":null}]`, `{"foo":["bar",null]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"a":{"b":[1]}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`, `{"a":{"b":[1]},"c":{"b":[1,2]}}`},
+		{`{"a/b":1,"m~n":2}`, `[{"op":"test","path":"/a~1b","value":1.0},{"op":"test","path":"/m~0n","value":2}]`, `{"a/b":1,"m~n":2}`},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``},
+		{`{"baz":"qux"}`, `[{"op":"remove","path":"/missing"}]`, ``},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":1}]`, ``},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``},
+		{`{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/x"}]`, ``},
+		{``, `[{"op":"add","path":"/a","value":1}]`, `{"a":1}`},
+	}
+
+	for _, item := range testcases {
+		op := new(driver.JSONPatchProcessor)
+		if err := op.Load([]byte(`{"patch":` + item.Patch + `}`)); err != nil {
+			t.Errorf("load patch %s fail: %s", item.Patch, err)
+			continue
+		}
+		after, err := op.Process(nil, []byte(item.Before))
+		if item.Expected == "" {
+			if err == nil {
+				t.Errorf("expected patch %s on %s fail, got: %s", item.Patch, item.Before, after)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("patch %s on %s fail: %s", item.Patch, item.Before, err)
+			continue
+		}
+		if string(after) != item.Expected {
+			t.Errorf("patch %s on %s expected %s, got %s", item.Patch, item.Before, item.Expected, after)
+		}
+	}
+
+	for _, patch := range []string{
+		`[{"op":"add","path":"/a"}]`,
+		`[{"op":"copy","path":"/a","from":"a"}]`,
+		`[{"op":"unknown","path":"/a"}]`,
+		`[{"op":"remove","path":"a"}]`,
+	} {
+		if err := new(driver.JSONPatchProcessor).Load([]byte(`{"patch":` + patch + `}`)); err == nil {
+			t.Errorf("expected load patch %s fail", patch)
+		}
+	}
+}
+
+func TestJSONMergePatchProcessor(t *testing.T) {
+	var testcases = []struct {
+		Before   string
+		Patch    string
+		Expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{``, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	d := driver.NewJSONDriver()
+	for _, item := range testcases {
+		data, err := d.Marshal(&driver.JSONMergePatchProcessor{Patch: json.RawMessage(item.Patch)})
+		if err != nil {
+			t.Errorf("marshal merge patch fail: %s", err)
+			continue
+		}
+		ops, err := d.Unmarshal(data)
+		if err != nil {
+			t.Errorf("unmarshal merge patch fail: %s", err)
+			continue
+		}
+		after, err := d.Realize(nil, []byte(item.Before), ops...)
+		if err != nil {
+			t.Errorf("merge patch %s on %s fail: %s", item.Patch, item.Before, err)
+			continue
+		}
+		if string(after) != item.Expected {
+			t.Errorf("merge patch %s on %s expected %s, got %s", item.Patch, item.Before, item.Expected, after)
+		}
+	}
+}
@@ -7,4 +7,8 @@ import (
 var (
 	// ErrSerializeNotSupport not support serialize error
 	ErrSerializeNotSupport = errors.New("Processor not support serialize")
+	// ErrUnknownDriver driver not registered
+	ErrUnknownDriver = errors.New("unknown driver")
+	// ErrUnknownProcessor processor kind not registered
+	ErrUnknownProcessor = errors.New("unknown processor kind")
 )
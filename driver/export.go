@@ -46,6 +46,13 @@ type Processor interface {
 	Path() string
 
 	// Type return processor type
+	// path based processors of json, toml, yaml and xml drivers share these types:
+	//   create:  add value at path, fail if it exists
+	//   set:     add or overwrite value at path
+	//   replace: overwrite value at path, fail if it does not exist
+	//   append:  add value to array at path, the array is created if not exists
+	//            (for xml, add another element at path)
+	//   delete:  remove value at path, fail if it does not exist
 	Type() string
 	// Process do process rule
 	Process(rc *RealizeContext, before []byte) (after []byte, err error)
@@ -70,3 +77,36 @@ type RealizeContext struct {
 	// ParentContent holds the realized content of the parent node.
 	ParentContent []byte
 }
+
+// WithParam returns a copy of rc with param key set to value.
+// rc itself is never modified, so shared contexts are safe to pass in.
+func (rc *RealizeContext) WithParam(key, value string) *RealizeContext {
+	c := rc.clone()
+	c.Params = make(map[string]string, len(c.Params)+1)
+	if rc != nil {
+		for k, v := range rc.Params {
+			c.Params[k] = v
+		}
+	}
+	c.Params[key] = value
+	return c
+}
+
+// WithNode returns a copy of rc describing the tree node being realized.
+func (rc *RealizeContext) WithNode(treePath string, parentContent []byte) *RealizeContext {
+	c := rc.clone()
+	c.TreePath = treePath
+	c.ParentContent = parentContent
+	return c
+}
+
+func (rc *RealizeContext) clone() *RealizeContext {
+	var c RealizeContext
+	if rc != nil {
+		c = *rc
+	}
+	if c.Context == nil {
+		c.Context = context.Background()
+	}
+	return &c
+}
@@ -1,10 +1,13 @@
 package driver
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
@@ -16,7 +19,8 @@ func NewJSONDriver() *JSONDriver {
 	return &JSONDriver{
 		PathParser: SlashPathParser,
 		Realizer:   new(StdRealizer),
-		Modem: &GeneralModem[*JSONProcessor]{
+		Modem: &RegistryModem{
+			Default:     "json",
 			Marshaler:   json.Marshal,
 			Unmarshaler: json.Unmarshal,
 		},
@@ -44,8 +48,15 @@ type JSONProcessor struct {
 	T string `json:"type"`
 	// JSONPath is the json path of the Processor
 	JSONPath string `json:"json_path"`
-	// V is the value of the Processor
+	// V is the value of the Processor.
+	// in json it is base64 without ValueType, as saved before ValueType existed,
+	// or the plain value with ValueType:
+	// a string as its text, any other json value as itself, like "abc", 1.5 or {"a":1}
 	V []byte `json:"value"`
+	// ValueType is how V is written: string, number, bool, null or raw json.
+	// empty means string for all types but set, which writes raw json.
+	// json value other than string loaded without ValueType is taken as raw
+	ValueType string `json:"value_type,omitempty"`
 
 	// A is the author of the Processor
 	A string `json:"author"`
@@ -61,21 +72,167 @@ func (op *JSONProcessor) Load(data []byte) error {
 	if err := json.Unmarshal(data, op); err != nil {
 		return fmt.Errorf("unmarshal fail: %w", err)
 	}
+	if _, _, err := op.value(); err != nil {
+		return err
+	}
 	return nil
 }
 func (op *JSONProcessor) Save() []byte {
 	data, _ := json.Marshal(op)
 	return data
 }
+
+// jsonProcessor is JSONProcessor without its json methods
+type jsonProcessor JSONProcessor
+
+// MarshalJSON write V as plain value if ValueType set
+func (op *JSONProcessor) MarshalJSON() ([]byte, error) {
+	var value any = op.V
+	if op.ValueType != "" {
+		value = string(op.V)
+		if v, err := jsonValue(op.ValueType, op.V); err == nil && op.ValueType != "string" {
+			value = json.RawMessage(v)
+		}
+	}
+	return json.Marshal(struct {
+		*jsonProcessor
+		V any `json:"value"`
+	}{(*jsonProcessor)(op), value})
+}
+
+// UnmarshalJSON read V as base64 or plain value, see V
+func (op *JSONProcessor) UnmarshalJSON(data []byte) error {
+	var v struct {
+		*jsonProcessor
+		V json.RawMessage `json:"value"`
+	}
+	v.jsonProcessor = (*jsonProcessor)(op)
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	value := bytes.TrimSpace(v.V)
+	switch {
+	case len(value) == 0 || string(value) == "null":
+		op.V = nil
+	case value[0] != '"':
+		if op.ValueType == "" {
+			op.ValueType = "raw"
+		}
+		op.V = value
+	case op.ValueType == "":
+		if err := json.Unmarshal(value, &op.V); err != nil {
+			return fmt.Errorf("value without value_type must be base64, set value_type for plain value: %w", err)
+		}
+	default:
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return err
+		}
+		op.V = []byte(s)
+	}
+	return nil
+}
+
 func (op *JSONProcessor) Process(_ *RealizeContext, before []byte) (after []byte, err error) {
+	value, raw, err := op.value()
+	if err != nil {
+		return nil, err
+	}
+
 	switch op.T {
-	case "create", "append", "replace":
-		return sjson.SetBytes(before, op.JSONPath, op.V)
+	case "create":
+		if gjson.GetBytes(before, op.JSONPath).Exists() {
+			return nil, fmt.Errorf("key already exists: %s", op.JSONPath)
+		}
+		return jsonSet(before, op.JSONPath, value, raw)
 	case "set":
-		return sjson.SetRawBytes(before, op.JSONPath, op.V)
+		return jsonSet(before, op.JSONPath, value, raw)
+	case "replace":
+		if !gjson.GetBytes(before, op.JSONPath).Exists() {
+			return nil, fmt.Errorf("key not found: %s", op.JSONPath)
+		}
+		return jsonSet(before, op.JSONPath, value, raw)
+	case "append":
+		return jsonAppend(before, op.JSONPath, value, raw)
 	case "delete":
+		if !gjson.GetBytes(before, op.JSONPath).Exists() {
+			return nil, fmt.Errorf("key not found: %s", op.JSONPath)
+		}
 		return sjson.DeleteBytes(before, op.JSONPath)
 	default:
 		return nil, fmt.Errorf("unknown Processor type: %s", op.T)
 	}
 }
+
+// value return value to write, raw is true if value is json
+func (op *JSONProcessor) value() (value []byte, raw bool, err error) {
+	if op.ValueType == "" {
+		return op.V, op.T == "set", nil
+	}
+	if value, err = jsonValue(op.ValueType, op.V); err != nil {
+		return nil, false, fmt.Errorf("invalid %s value %q: %w", op.ValueType, op.V, err)
+	}
+	return value, true, nil
+}
+
+// jsonValue encode value of type into json
+func jsonValue(typ string, value []byte) ([]byte, error) {
+	switch trimmed := bytes.TrimSpace(value); typ {
+	case "string":
+		return json.Marshal(string(value))
+	case "number":
+		var v any
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.UseNumber()
+		if !json.Valid(trimmed) || dec.Decode(&v) != nil {
+			return nil, fmt.Errorf("not a number")
+		}
+		if _, ok := v.(json.Number); !ok {
+			return nil, fmt.Errorf("not a number")
+		}
+		return trimmed, nil
+	case "bool":
+		if s := string(trimmed); s != "true" && s != "false" {
+			return nil, fmt.Errorf("not a bool")
+		}
+		return trimmed, nil
+	case "null":
+		if len(trimmed) != 0 && string(trimmed) != "null" {
+			return nil, fmt.Errorf("not null")
+		}
+		return []byte("null"), nil
+	case "raw":
+		if !json.Valid(trimmed) {
+			return nil, fmt.Errorf("not valid json")
+		}
+		return trimmed, nil
+	default:
+		return nil, fmt.Errorf("unknown value type")
+	}
+}
+
+// jsonSet set value at path, raw value is written as json, otherwise as string
+func jsonSet(before []byte, path string, value []byte, raw bool) ([]byte, error) {
+	if raw {
+		return sjson.SetRawBytes(before, path, value)
+	}
+	return sjson.SetBytes(before, path, value)
+}
+
+// jsonAppend append value to array at path, creating the array if not exists.
+// path ending with ".-1" is accepted as the array itself, as written for sjson.
+func jsonAppend(before []byte, path string, value []byte, raw bool) ([]byte, error) {
+	path = strings.TrimSuffix(path, ".-1")
+
+	switch result := gjson.GetBytes(before, path); {
+	case !result.Exists():
+		var err error
+		if before, err = sjson.SetRawBytes(before, path, []byte("[]")); err != nil {
+			return nil, err
+		}
+	case !result.IsArray():
+		return nil, fmt.Errorf("key %s is not an array", path)
+	}
+	return jsonSet(before, path+".-1", value, raw)
+}
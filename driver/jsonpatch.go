@@ -0,0 +1,404 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPatch is a JSON Patch document, see RFC 6902
+type JSONPatch []JSONPatchOperation
+
+// JSONPatchOperation is an operation in JSON Patch document
+type JSONPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply apply patch operations in order on JSON document doc, empty doc is taken as an empty object.
+// It fails if any operation fails, including a failed test.
+func (p JSONPatch) Apply(doc []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(doc)) == 0 {
+		doc = []byte("{}")
+	}
+	root, err := parseJSON(doc)
+	if err != nil {
+		return nil, fmt.Errorf("decode document fail: %w", err)
+	}
+	for i, op := range p {
+		if root, err = op.apply(root); err != nil {
+			return nil, fmt.Errorf("patch operation %d %s on %s fail: %w", i, op.Op, op.Path, err)
+		}
+	}
+	return encodeJSON(root)
+}
+
+// validate check if operations are well formed, without a document
+func (p JSONPatch) validate() error {
+	for i, op := range p {
+		if err := op.validate(); err != nil {
+			return fmt.Errorf("patch operation %d %s on %s invalid: %w", i, op.Op, op.Path, err)
+		}
+	}
+	return nil
+}
+
+func (op *JSONPatchOperation) validate() error {
+	if _, err := splitPointer(op.Path); err != nil {
+		return err
+	}
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return fmt.Errorf("missing value")
+		}
+		if !json.Valid(op.Value) {
+			return fmt.Errorf("invalid value")
+		}
+	case "remove":
+	case "move", "copy":
+		if _, err := splitPointer(op.From); err != nil {
+			return fmt.Errorf("invalid from: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown op")
+	}
+	return nil
+}
+
+// apply apply operation on decoded document, return the new root
+func (op *JSONPatchOperation) apply(root any) (any, error) {
+	if err := op.validate(); err != nil {
+		return nil, err
+	}
+	path, _ := splitPointer(op.Path)
+
+	switch op.Op {
+	case "add":
+		v, err := parseJSON(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return addJSON(root, path, v)
+	case "remove":
+		root, _, err := removeJSON(root, path)
+		return root, err
+	case "replace":
+		v, err := parseJSON(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return replaceJSON(root, path, v)
+	case "move":
+		if op.From == op.Path {
+			return root, nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("cannot move %s into its child", op.From)
+		}
+		from, _ := splitPointer(op.From)
+		root, v, err := removeJSON(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return addJSON(root, path, v)
+	case "copy":
+		from, _ := splitPointer(op.From)
+		v, err := getJSON(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return addJSON(root, path, copyJSON(v))
+	default: // test
+		want, err := parseJSON(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		v, err := getJSON(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equalJSON(v, want) {
+			return nil, fmt.Errorf("test fail: value is not %s", op.Value)
+		}
+		return root, nil
+	}
+}
+
+// splitPointer split JSON Pointer into unescaped reference tokens, see RFC 6901
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parse array index token, "-" is allowed as n when end is true
+func arrayIndex(token string, n int, end bool) (int, error) {
+	if token == "-" && end {
+		return n, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.Trim(token, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i > n || (i == n && !end) {
+		return 0, fmt.Errorf("array index %s out of range", token)
+	}
+	return i, nil
+}
+
+// getJSON get value at path in decoded document
+func getJSON(v any, path []string) (any, error) {
+	for _, token := range path {
+		switch c := v.(type) {
+		case *jsonObject:
+			var ok bool
+			if v, ok = c.get(token); !ok {
+				return nil, fmt.Errorf("key not found: %s", token)
+			}
+		case []any:
+			i, err := arrayIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			v = c[i]
+		default:
+			return nil, fmt.Errorf("cannot get %s from a value", token)
+		}
+	}
+	return v, nil
+}
+
+// updateJSON replace the container holding the last token of path by fn, return the new v
+func updateJSON(v any, path []string, fn func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(v, path[0])
+	}
+
+	child, err := getJSON(v, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	if child, err = updateJSON(child, path[1:], fn); err != nil {
+		return nil, err
+	}
+	switch c := v.(type) {
+	case *jsonObject:
+		c.set(path[0], child)
+	case []any:
+		i, _ := arrayIndex(path[0], len(c), false)
+		c[i] = child
+	}
+	return v, nil
+}
+
+// addJSON add value at path, return the new root
+func addJSON(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateJSON(root, path, func(parent any, token string) (any, error) {
+		switch c := parent.(type) {
+		case *jsonObject:
+			c.set(token, value)
+			return c, nil
+		case []any:
+			i, err := arrayIndex(token, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		default:
+			return nil, fmt.Errorf("cannot add %s to a value", token)
+		}
+	})
+}
+
+// replaceJSON replace existing value at path in place, return the new root
+func replaceJSON(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return updateJSON(root, path, func(parent any, token string) (any, error) {
+		if _, err := getJSON(parent, []string{token}); err != nil {
+			return nil, err
+		}
+		switch c := parent.(type) {
+		case *jsonObject:
+			c.set(token, value)
+		case []any:
+			i, _ := arrayIndex(token, len(c), false)
+			c[i] = value
+		}
+		return parent, nil
+	})
+}
+
+// removeJSON remove value at path, return the new root and value removed
+func removeJSON(root any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, root, nil
+	}
+	var removed any
+	root, err := updateJSON(root, path, func(parent any, token string) (any, error) {
+		switch c := parent.(type) {
+		case *jsonObject:
+			var ok bool
+			if removed, ok = c.get(token); !ok {
+				return nil, fmt.Errorf("key not found: %s", token)
+			}
+			c.del(token)
+			return c, nil
+		case []any:
+			i, err := arrayIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = c[i]
+			return append(c[:i:i], c[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("cannot remove %s from a value", token)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return root, removed, nil
+}
+
+// DiffJSON return the JSON Patch turning before into after.
+// objects are compared by keys, arrays by index, other values are replaced as a whole.
+func DiffJSON(before, after []byte) (JSONPatch, error) {
+	x, err := decodeJSON(before)
+	if err != nil {
+		return nil, fmt.Errorf("decode before fail: %w", err)
+	}
+	y, err := decodeJSON(after)
+	if err != nil {
+		return nil, fmt.Errorf("decode after fail: %w", err)
+	}
+
+	var patch JSONPatch
+	if err := diffJSON("", x, y, &patch); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+func diffJSON(path string, x, y any, patch *JSONPatch) error {
+	if reflect.DeepEqual(x, y) {
+		return nil
+	}
+
+	switch x := x.(type) {
+	case map[string]any:
+		if y, ok := y.(map[string]any); ok {
+			return diffJSONObject(path, x, y, patch)
+		}
+	case []any:
+		if y, ok := y.([]any); ok {
+			return diffJSONArray(path, x, y, patch)
+		}
+	}
+	return patch.add("replace", path, y)
+}
+
+func diffJSONObject(path string, x, y map[string]any, patch *JSONPatch) error {
+	for _, key := range sortedKeys(x) {
+		if _, ok := y[key]; !ok {
+			if err := patch.add("remove", appendPointer(path, key), nil); err != nil {
+				return err
+			}
+		}
+	}
+	for _, key := range sortedKeys(y) {
+		var err error
+		if v, ok := x[key]; ok {
+			err = diffJSON(appendPointer(path, key), v, y[key], patch)
+		} else {
+			err = patch.add("add", appendPointer(path, key), y[key])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffJSONArray(path string, x, y []any, patch *JSONPatch) error {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	for i := 0; i < n; i++ {
+		if err := diffJSON(appendPointer(path, strconv.Itoa(i)), x[i], y[i], patch); err != nil {
+			return err
+		}
+	}
+	// remove from the end, so indexes of elements left are not shifted
+	for i := len(x) - 1; i >= n; i-- {
+		if err := patch.add("remove", appendPointer(path, strconv.Itoa(i)), nil); err != nil {
+			return err
+		}
+	}
+	for i := n; i < len(y); i++ {
+		if err := patch.add("add", appendPointer(path, strconv.Itoa(i)), y[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *JSONPatch) add(op, path string, value any) error {
+	operation := JSONPatchOperation{Op: op, Path: path}
+	if op != "remove" {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("marshal value on %s fail: %w", path, err)
+		}
+		operation.Value = data
+	}
+	*p = append(*p, operation)
+	return nil
+}
+
+// decodeJSON decode data keeping numbers as they are
+func decodeJSON(data []byte) (v any, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// appendPointer append an escaped reference token to JSON Pointer, see RFC 6901
+func appendPointer(pointer, token string) string {
+	return pointer + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
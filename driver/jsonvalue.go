@@ -0,0 +1,190 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// jsonObject is a decoded json object keeping key order,
+// so documents patched on decoded values are encoded in their original order.
+type jsonObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func newJSONObject() *jsonObject { return &jsonObject{values: make(map[string]any)} }
+
+func (o *jsonObject) get(key string) (v any, ok bool) {
+	v, ok = o.values[key]
+	return v, ok
+}
+
+// set set value of key, new key is added to the end
+func (o *jsonObject) set(key string, v any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+func (o *jsonObject) del(key string) bool {
+	if _, ok := o.values[key]; !ok {
+		return false
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i:i], o.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := encodeJSON(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := encodeJSON(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// parseJSON decode data into *jsonObject, []any, json.Number, string, bool or nil
+func parseJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := parseJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("invalid data after top-level value")
+	}
+	return v, nil
+}
+
+func parseJSONValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := newJSONObject()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := parseJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.set(key.(string), v)
+		}
+		_, err = dec.Token() // }
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			v, err := parseJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err = dec.Token() // ]
+		return arr, err
+	default:
+		return tok, nil
+	}
+}
+
+// encodeJSON encode decoded value without escaping html characters, like sjson does
+func encodeJSON(v any) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// copyJSON deep copy decoded value
+func copyJSON(v any) any {
+	switch v := v.(type) {
+	case *jsonObject:
+		c := newJSONObject()
+		for _, key := range v.keys {
+			c.set(key, copyJSON(v.values[key]))
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, item := range v {
+			c[i] = copyJSON(item)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// equalJSON check if decoded values are equal, objects regardless of key order and numbers by value
+func equalJSON(x, y any) bool {
+	switch x := x.(type) {
+	case *jsonObject:
+		y, ok := y.(*jsonObject)
+		if !ok || len(x.keys) != len(y.keys) {
+			return false
+		}
+		for key, v := range x.values {
+			if w, ok := y.values[key]; !ok || !equalJSON(v, w) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := y.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equalJSON(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := y.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		a, errA := strconv.ParseFloat(string(x), 64)
+		b, errB := strconv.ParseFloat(string(y), 64)
+		return errA == nil && errB == nil && a == b
+	default:
+		return x == y
+	}
+}
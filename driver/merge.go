@@ -0,0 +1,399 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var _ Processor = (*MergeProcessor)(nil)
+
+// MergeProcessor is a Processor deep merging a document fragment onto rule,
+// for JSON, YAML and TOML type rule trees.
+// objects are merged by key, other values in fragment replace those in rule,
+// arrays and nulls are merged by strategies.
+type MergeProcessor struct {
+	// P is the target path of the Processor
+	P string `json:"path"`
+
+	// Format is the format of rule and fragment: json, yaml or toml
+	Format string `json:"format"`
+	// V is the document fragment to merge
+	V []byte `json:"value"`
+	// Strategies are how arrays and nulls are merged, by path
+	Strategies []MergeStrategy `json:"strategies,omitempty"`
+
+	// A is the author of the Processor
+	A string `json:"author"`
+	// C is the create time of the Processor
+	C time.Time `json:"created_at"`
+}
+
+// MergeStrategy is how values at path and under it are merged,
+// unless overridden by a strategy on a longer path.
+type MergeStrategy struct {
+	// Path is dot-separated keys or array indexes like "servers.0.tags",
+	// "*" matches any key or index, empty for the whole document
+	Path string `json:"path"`
+
+	// Arrays is how an array in fragment is merged onto an array in rule:
+	//   replace: fragment array replaces rule array, the default
+	//   append:  fragment elements are appended
+	//   union:   fragment elements not in rule are appended, elements are matched by
+	//            Key field and merged if Key is set, or compared as a whole otherwise
+	Arrays string `json:"arrays,omitempty"`
+	// Key is the field identifying objects in array for union
+	Key string `json:"key,omitempty"`
+
+	// Nulls is how a null in fragment is merged:
+	//   delete: the key is deleted from rule, the default
+	//   keep:   null is set as value
+	Nulls string `json:"nulls,omitempty"`
+}
+
+func (op *MergeProcessor) Type() string         { return "merge" }
+func (op *MergeProcessor) Path() string         { return op.P }
+func (op *MergeProcessor) Author() string       { return op.A }
+func (op *MergeProcessor) CreatedAt() time.Time { return op.C }
+func (op *MergeProcessor) Load(data []byte) error {
+	if err := json.Unmarshal(data, op); err != nil {
+		return fmt.Errorf("unmarshal fail: %w", err)
+	}
+	return op.validate()
+}
+func (op *MergeProcessor) Save() []byte {
+	data, _ := json.Marshal(op)
+	return data
+}
+
+func (op *MergeProcessor) validate() error {
+	switch op.Format {
+	case "json", "yaml", "toml":
+	default:
+		return fmt.Errorf("unknown merge format: %s", op.Format)
+	}
+	for _, s := range op.Strategies {
+		switch s.Arrays {
+		case "", "replace", "append", "union":
+		default:
+			return fmt.Errorf("unknown arrays strategy on %q: %s", s.Path, s.Arrays)
+		}
+		switch s.Nulls {
+		case "", "delete", "keep":
+		default:
+			return fmt.Errorf("unknown nulls strategy on %q: %s", s.Path, s.Nulls)
+		}
+	}
+	return nil
+}
+
+func (op *MergeProcessor) Process(_ *RealizeContext, before []byte) (after []byte, err error) {
+	if err := op.validate(); err != nil {
+		return nil, err
+	}
+	m := merger{strategies: op.Strategies}
+
+	switch op.Format {
+	case "yaml":
+		doc, err := yamlCodec.parse(before)
+		if err != nil {
+			return nil, err
+		}
+		fragment, err := yamlCodec.parse(op.V)
+		if err != nil {
+			return nil, fmt.Errorf("parse fragment fail: %w", err)
+		}
+		doc.Content[0] = m.mergeYAML(nil, doc.Content[0], fragment.Content[0])
+		if err := yamlCheckAliases(doc); err != nil {
+			return nil, err
+		}
+		return yamlCodec.serialize(doc)
+	case "toml":
+		doc, err := tomlCodec.parse(before)
+		if err != nil {
+			return nil, err
+		}
+		fragment, err := tomlCodec.parse(op.V)
+		if err != nil {
+			return nil, fmt.Errorf("parse fragment fail: %w", err)
+		}
+		merged, _ := unorderValue(m.merge(nil, orderValue(doc), orderValue(fragment))).(map[string]any)
+		return tomlCodec.serialize(merged)
+	default: // json
+		var doc any
+		if len(strings.TrimSpace(string(before))) > 0 {
+			if doc, err = parseJSON(before); err != nil {
+				return nil, fmt.Errorf("decode document fail: %w", err)
+			}
+		}
+		fragment, err := parseJSON(op.V)
+		if err != nil {
+			return nil, fmt.Errorf("decode fragment fail: %w", err)
+		}
+		return encodeJSON(m.merge(nil, doc, fragment))
+	}
+}
+
+// merger deep merge values by strategies
+type merger struct {
+	strategies []MergeStrategy
+}
+
+// strategy return strategy field on path picked by get from the longest matching strategy path
+func (m merger) strategy(path []string, get func(MergeStrategy) string) (MergeStrategy, string) {
+	var (
+		best     MergeStrategy
+		value    string
+		bestSize = -1
+	)
+	for _, s := range m.strategies {
+		v := get(s)
+		if v == "" {
+			continue
+		}
+		segments := splitMergePath(s.Path)
+		if len(segments) > len(path) || len(segments) <= bestSize {
+			continue
+		}
+		matched := true
+		for i, seg := range segments {
+			if seg != "*" && seg != path[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			best, value, bestSize = s, v, len(segments)
+		}
+	}
+	return best, value
+}
+
+func (m merger) arrays(path []string) (mode, key string) {
+	s, mode := m.strategy(path, func(s MergeStrategy) string { return s.Arrays })
+	return mode, s.Key
+}
+
+func (m merger) keepNull(path []string) bool {
+	_, nulls := m.strategy(path, func(s MergeStrategy) string { return s.Nulls })
+	return nulls == "keep"
+}
+
+func splitMergePath(path string) []string {
+	path = strings.Trim(path, ".")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// childPath return path of child, path is never modified
+func childPath(path []string, name string) []string {
+	return append(path[:len(path):len(path)], name)
+}
+
+// merge merge decoded fragment src onto dst at path, return merged value
+func (m merger) merge(path []string, dst, src any) any {
+	switch s := src.(type) {
+	case *jsonObject:
+		d, ok := dst.(*jsonObject)
+		if !ok {
+			d = newJSONObject()
+		}
+		for _, key := range s.keys {
+			v, p := s.values[key], childPath(path, key)
+			if v == nil && !m.keepNull(p) {
+				d.del(key)
+				continue
+			}
+			old, _ := d.get(key)
+			d.set(key, m.merge(p, old, v))
+		}
+		return d
+	case []any:
+		d, ok := dst.([]any)
+		if !ok {
+			return src
+		}
+		switch mode, key := m.arrays(path); mode {
+		case "append":
+			return append(d, s...)
+		case "union":
+			return m.union(path, d, s, key)
+		default:
+			return src
+		}
+	default:
+		return src
+	}
+}
+
+// union merge elements of src not in dst into dst
+func (m merger) union(path []string, dst, src []any, key string) []any {
+	for _, v := range src {
+		i := -1
+		for j, w := range dst {
+			if matchElement(w, v, key) {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			dst = append(dst, v)
+			continue
+		}
+		if key != "" {
+			dst[i] = m.merge(childPath(path, strconv.Itoa(i)), dst[i], v)
+		}
+	}
+	return dst
+}
+
+// matchElement check if array elements are the same, by key field if key set and both have it
+func matchElement(x, y any, key string) bool {
+	if key != "" {
+		a, okA := x.(*jsonObject)
+		b, okB := y.(*jsonObject)
+		if okA && okB {
+			u, okA := a.get(key)
+			v, okB := b.get(key)
+			if okA && okB {
+				return equalJSON(u, v)
+			}
+		}
+	}
+	return equalJSON(x, y)
+}
+
+// orderValue convert decoded maps into jsonObject with keys sorted
+func orderValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		o := newJSONObject()
+		for _, key := range keys {
+			o.set(key, orderValue(v[key]))
+		}
+		return o
+	case []any:
+		for i := range v {
+			v[i] = orderValue(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// unorderValue convert jsonObject back into maps
+func unorderValue(v any) any {
+	switch v := v.(type) {
+	case *jsonObject:
+		m := make(map[string]any, len(v.keys))
+		for _, key := range v.keys {
+			m[key] = unorderValue(v.values[key])
+		}
+		return m
+	case []any:
+		for i := range v {
+			v[i] = unorderValue(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// mergeYAML merge yaml fragment node src onto dst at path, return merged node.
+// nodes in dst are kept when merged, so are their comments and anchors,
+// replacing nodes take over comments and anchor of the replaced.
+func (m merger) mergeYAML(path []string, dst, src *yaml.Node) *yaml.Node {
+	switch {
+	case src.Kind == yaml.MappingNode:
+		if dst == nil || dst.Kind != yaml.MappingNode {
+			dst = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, v := src.Content[i], src.Content[i+1]
+			p, seg := childPath(path, key.Value), yamlSegment{key: key.Value, index: -1}
+			j, _ := yamlChild(dst, seg)
+			if isYAMLNull(v) && !m.keepNull(p) {
+				if j >= 0 {
+					dst.Content = append(dst.Content[:j-1], dst.Content[j+1:]...)
+				}
+				continue
+			}
+			if j < 0 {
+				dst.Content = append(dst.Content, key, m.mergeYAML(p, nil, v))
+				continue
+			}
+			dst.Content[j] = m.mergeYAML(p, dst.Content[j], v)
+		}
+		return dst
+	case src.Kind == yaml.SequenceNode && dst != nil && dst.Kind == yaml.SequenceNode:
+		switch mode, key := m.arrays(path); mode {
+		case "append":
+			dst.Content = append(dst.Content, src.Content...)
+			return dst
+		case "union":
+			for _, v := range src.Content {
+				i := -1
+				for j, w := range dst.Content {
+					if matchYAMLElement(w, v, key) {
+						i = j
+						break
+					}
+				}
+				if i < 0 {
+					dst.Content = append(dst.Content, v)
+				} else if key != "" {
+					dst.Content[i] = m.mergeYAML(childPath(path, strconv.Itoa(i)), dst.Content[i], v)
+				}
+			}
+			return dst
+		}
+	}
+
+	if dst != nil && src.HeadComment == "" && src.LineComment == "" && src.FootComment == "" {
+		src.HeadComment, src.LineComment, src.FootComment = dst.HeadComment, dst.LineComment, dst.FootComment
+	}
+	if dst != nil && src.Anchor == "" && src.Kind != yaml.AliasNode {
+		src.Anchor = dst.Anchor
+	}
+	return src
+}
+
+func isYAMLNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
+
+// matchYAMLElement check if sequence elements are the same, by key field if key set and both have it
+func matchYAMLElement(x, y *yaml.Node, key string) bool {
+	if key != "" {
+		seg := yamlSegment{key: key, index: -1}
+		i, errX := yamlChild(x, seg)
+		j, errY := yamlChild(y, seg)
+		if errX == nil && errY == nil && i >= 0 && j >= 0 {
+			return equalYAML(x.Content[i], y.Content[j])
+		}
+	}
+	return equalYAML(x, y)
+}
+
+func equalYAML(x, y *yaml.Node) bool {
+	var u, v any
+	if x.Decode(&u) != nil || y.Decode(&v) != nil {
+		return false
+	}
+	return reflect.DeepEqual(u, v)
+}
@@ -0,0 +1,146 @@
+package driver_test
+
+import (
+	"testing"
+
+	"github.com/tr1v3r/ivy/driver"
+)
+
+func TestMergeProcessor_JSON(t *testing.T) {
+	before := `{"name":"svc","labels":{"env":"dev","team":"a"},"tags":["a"],"ports":[80],` +
+		`"servers":[{"host":"a","port":1},{"host":"b","port":2}]}`
+
+	var testcases = []struct {
+		Fragment   string
+		Strategies []driver.MergeStrategy
+		Expected   string
+	}{
+		{ // objects merged, arrays replaced, nulls deleted by default
+			`{"labels":{"env":"prod","team":null},"tags":["b"],"extra":{"x":null,"y":1}}`, nil,
+			`{"name":"svc","labels":{"env":"prod"},"tags":["b"],"ports":[80],"servers":[{"host":"a","port":1},{"host":"b","port":2}],"extra":{"y":1}}`,
+		},
+		{
+			`{"tags":["a","b"],"ports":[443],"labels":{"team":null}}`,
+			[]driver.MergeStrategy{{Arrays: "union"}, {Path: "ports", Arrays: "append"}, {Path: "labels", Nulls: "keep"}},
+			`{"name":"svc","labels":{"env":"dev","team":null},"tags":["a","b"],"ports":[80,443],"servers":[{"host":"a","port":1},{"host":"b","port":2}]}`,
+		},
+		{ // union by key merges matched elements
+			`{"servers":[{"host":"b","port":3,"tls":true},{"host":"c"}]}`,
+			[]driver.MergeStrategy{{Path: "servers", Arrays: "union", Key: "host"}, {Path: "servers.*.port", Nulls: "keep"}},
+			`{"name":"svc","labels":{"env":"dev","team":"a"},"tags":["a"],"ports":[80],"servers":[{"host":"a","port":1},{"host":"b","port":3,"tls":true},{"host":"c"}]}`,
+		},
+	}
+
+	for _, item := range testcases {
+		op := &driver.MergeProcessor{Format: "json", V: []byte(item.Fragment), Strategies: item.Strategies}
+		after, err := op.Process(nil, []byte(before))
+		if err != nil {
+			t.Errorf("merge %s fail: %s", item.Fragment, err)
+			continue
+		}
+		if string(after) != item.Expected {
+			t.Errorf("merge %s expected %s, got %s", item.Fragment, item.Expected, after)
+		}
+	}
+}
+
+func TestMergeProcessor_YAML(t *testing.T) {
+	before := `# service
+name: svc # keep me
+labels:
+  env: dev
+  team: a
+servers:
+  - host: a
+    port: 1
+`
+	op := &driver.MergeProcessor{
+		Format:     "yaml",
+		V:          []byte("name: api\nlabels: {team: ~, zone: cn}\nservers: [{host: a, port: 2}, {host: b}]\n"),
+		Strategies: []driver.MergeStrategy{{Path: "servers", Arrays: "union", Key: "host"}},
+	}
+	after, err := driver.NewYAMLDriver().Realize(nil, []byte(before), op)
+	if err != nil {
+		t.Errorf("merge fail: %s", err)
+		return
+	}
+
+	expected := `# service
+name: api # keep me
+labels:
+  env: dev
+  zone: cn
+servers:
+  - host: a
+    port: 2
+  - {host: b}
+`
+	if string(after) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, after)
+	}
+}
+
+func TestMergeProcessor_YAMLAnchor(t *testing.T) {
+	op := &driver.MergeProcessor{Format: "yaml", V: []byte("tags: [z]\n")}
+	after, err := op.Process(nil, []byte("tags: &t [a]\ncopy: *t\n"))
+	if err != nil {
+		t.Errorf("merge fail: %s", err)
+		return
+	}
+	expected := "tags: &t [z]\ncopy: *t\n"
+	if string(after) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, after)
+	}
+
+	op = &driver.MergeProcessor{Format: "yaml", V: []byte("tags: ~\n")}
+	if after, err := op.Process(nil, []byte("tags: &t [a]\ncopy: *t\n")); err == nil {
+		t.Errorf("expected error deleting referenced anchor, got: %s", after)
+	}
+}
+
+func TestMergeProcessor_TOML(t *testing.T) {
+	before := "name = \"svc\"\ntags = [\"a\"]\n\n[labels]\nenv = \"dev\"\n"
+	op := &driver.MergeProcessor{
+		Format:     "toml",
+		V:          []byte("tags = [\"a\", \"b\"]\n\n[labels]\nteam = \"x\"\n"),
+		Strategies: []driver.MergeStrategy{{Path: "tags", Arrays: "union"}},
+	}
+	after, err := driver.NewTOMLDriver().Realize(nil, []byte(before), op)
+	if err != nil {
+		t.Errorf("merge fail: %s", err)
+		return
+	}
+
+	expected := "name = 'svc'\ntags = ['a', 'b']\n\n[labels]\nenv = 'dev'\nteam = 'x'\n"
+	if string(after) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, after)
+	}
+}
+
+func TestMergeProcessor_Load(t *testing.T) {
+	d := driver.NewJSONDriver()
+	data, err := d.Marshal(&driver.MergeProcessor{Format: "json", V: []byte(`{"a":1}`),
+		Strategies: []driver.MergeStrategy{{Path: "list", Arrays: "append"}}})
+	if err != nil {
+		t.Errorf("marshal fail: %s", err)
+		return
+	}
+	ops, err := d.Unmarshal(data)
+	if err != nil {
+		t.Errorf("unmarshal fail: %s", err)
+		return
+	}
+	if op, ok := ops[0].(*driver.MergeProcessor); !ok || op.Strategies[0].Arrays != "append" {
+		t.Errorf("expected merge processor restored, got %+v", ops[0])
+	}
+
+	for _, data := range []string{
+		`{"format":"xml"}`,
+		`{"format":"json","strategies":[{"arrays":"zip"}]}`,
+		`{"format":"json","strategies":[{"nulls":"ignore"}]}`,
+	} {
+		if err := new(driver.MergeProcessor).Load([]byte(data)); err == nil {
+			t.Errorf("expected load %s fail", data)
+		}
+	}
+}
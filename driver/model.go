@@ -0,0 +1,72 @@
+package driver
+
+import "fmt"
+
+// modelProcessor is a Processor working on parsed document model M,
+// so consecutive processors on a node share one parse and one serialization.
+type modelProcessor[M any] interface {
+	Processor
+
+	// apply do process on model in place
+	apply(m M) error
+}
+
+// modelCodec parse and serialize document model M
+type modelCodec[M any] struct {
+	parse     func(data []byte) (M, error)
+	serialize func(m M) ([]byte, error)
+}
+
+// process do a single processor on document
+func (c modelCodec[M]) process(proc modelProcessor[M], before []byte) ([]byte, error) {
+	m, err := c.parse(before)
+	if err != nil {
+		return nil, err
+	}
+	if err := proc.apply(m); err != nil {
+		return nil, err
+	}
+	return c.serialize(m)
+}
+
+// realize do processors on rule, runs of modelProcessor are applied on one parsed model,
+// other processors get serialized document as usual.
+func (c modelCodec[M]) realize(rc *RealizeContext, rule []byte, procs ...Processor) ([]byte, error) {
+	var (
+		m      M
+		parsed bool
+		err    error
+	)
+	for _, proc := range procs {
+		if proc == nil {
+			continue
+		}
+
+		if p, ok := proc.(modelProcessor[M]); ok {
+			if !parsed {
+				if m, err = c.parse(rule); err != nil {
+					return nil, fmt.Errorf("do %s on %s fail: %w", proc.Type(), proc.Path(), err)
+				}
+				parsed = true
+			}
+			if err = p.apply(m); err != nil {
+				return nil, fmt.Errorf("do %s on %s fail: %w", proc.Type(), proc.Path(), err)
+			}
+			continue
+		}
+
+		if parsed {
+			if rule, err = c.serialize(m); err != nil {
+				return nil, err
+			}
+			parsed = false
+		}
+		if rule, err = proc.Process(rc, rule); err != nil {
+			return nil, fmt.Errorf("do %s on %s fail: %w", proc.Type(), proc.Path(), err)
+		}
+	}
+	if parsed {
+		return c.serialize(m)
+	}
+	return rule, nil
+}
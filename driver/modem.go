@@ -7,7 +7,10 @@ import (
 	"reflect"
 )
 
-var _ Modem = (*GeneralModem[Processor])(nil)
+var (
+	_ Modem = (*GeneralModem[Processor])(nil)
+	_ Modem = (*RegistryModem)(nil)
+)
 
 // GeneralModem json moden
 type GeneralModem[T Processor] struct {
@@ -56,3 +59,73 @@ func (m *GeneralModem[T]) checkType() (reflect.Type, error) {
 	}
 	return typ, nil
 }
+
+// RegistryModem polymorphic modem
+// processors are saved along with the kind they registered as,
+// so lists of mixed processor types can be marshaled and unmarshaled.
+type RegistryModem struct {
+	// Registry to look up processor kinds, DefaultRegistry if nil
+	Registry *Registry
+	// Default is the kind for items saved without kind, e.g. by GeneralModem
+	Default string
+
+	Marshaler   func(in any) (out []byte, err error)
+	Unmarshaler func(data []byte, v any) error
+}
+
+// typedProcessor saved processor with its kind
+type typedProcessor struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+func (m *RegistryModem) Marshal(ops ...Processor) ([]byte, error) {
+	var buf = make([]typedProcessor, 0, len(ops))
+	for _, op := range ops {
+		kind, ok := m.registry().KindOf(op)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T", ErrUnknownProcessor, op)
+		}
+		data := op.Save()
+		if data == nil {
+			return nil, fmt.Errorf("save %s processor fail: %w", kind, ErrSerializeNotSupport)
+		}
+		buf = append(buf, typedProcessor{Kind: kind, Data: data})
+	}
+	return m.Marshaler(buf)
+}
+func (m *RegistryModem) Unmarshal(data []byte) ([]Processor, error) {
+	var buf = make([]json.RawMessage, 0, 8)
+	if err := m.Unmarshaler(data, &buf); err != nil {
+		return nil, fmt.Errorf("unmarshal fail: %w", err)
+	}
+
+	var ops = make([]Processor, 0, len(buf))
+	for _, item := range buf {
+		kind, data := m.Default, item
+		var typed typedProcessor
+		if json.Unmarshal(item, &typed) == nil && typed.Kind != "" && typed.Data != nil {
+			kind, data = typed.Kind, typed.Data
+		}
+		if kind == "" {
+			return nil, fmt.Errorf("load Processor fail: no kind found in %s", item)
+		}
+
+		op, err := m.registry().New(kind)
+		if err != nil {
+			return nil, fmt.Errorf("load Processor fail: %w", err)
+		}
+		if err := op.Load(data); err != nil {
+			return nil, fmt.Errorf("load %s Processor fail: %w", kind, err)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func (m *RegistryModem) registry() *Registry {
+	if m.Registry == nil {
+		return DefaultRegistry
+	}
+	return m.Registry
+}
@@ -0,0 +1,119 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+var _ Processor = (*JSONPatchProcessor)(nil)
+
+// JSONPatchProcessor is a Processor applying JSON Patch on JSON type rule tree, see RFC 6902
+type JSONPatchProcessor struct {
+	// P is the target path of the Processor
+	P string `json:"path"`
+
+	// Patch is the JSON Patch document
+	Patch JSONPatch `json:"patch"`
+
+	// A is the author of the Processor
+	A string `json:"author"`
+	// C is the create time of the Processor
+	C time.Time `json:"created_at"`
+}
+
+func (op *JSONPatchProcessor) Type() string         { return "json_patch" }
+func (op *JSONPatchProcessor) Path() string         { return op.P }
+func (op *JSONPatchProcessor) Author() string       { return op.A }
+func (op *JSONPatchProcessor) CreatedAt() time.Time { return op.C }
+func (op *JSONPatchProcessor) Load(data []byte) error {
+	if err := json.Unmarshal(data, op); err != nil {
+		return fmt.Errorf("unmarshal fail: %w", err)
+	}
+	return op.Patch.validate()
+}
+func (op *JSONPatchProcessor) Save() []byte {
+	data, _ := json.Marshal(op)
+	return data
+}
+
+func (op *JSONPatchProcessor) Process(_ *RealizeContext, before []byte) (after []byte, err error) {
+	return op.Patch.Apply(before)
+}
+
+var _ Processor = (*JSONMergePatchProcessor)(nil)
+
+// JSONMergePatchProcessor is a Processor applying JSON Merge Patch on JSON type rule tree, see RFC 7396
+type JSONMergePatchProcessor struct {
+	// P is the target path of the Processor
+	P string `json:"path"`
+
+	// Patch is the JSON Merge Patch document
+	Patch json.RawMessage `json:"patch"`
+
+	// A is the author of the Processor
+	A string `json:"author"`
+	// C is the create time of the Processor
+	C time.Time `json:"created_at"`
+}
+
+func (op *JSONMergePatchProcessor) Type() string         { return "json_merge_patch" }
+func (op *JSONMergePatchProcessor) Path() string         { return op.P }
+func (op *JSONMergePatchProcessor) Author() string       { return op.A }
+func (op *JSONMergePatchProcessor) CreatedAt() time.Time { return op.C }
+func (op *JSONMergePatchProcessor) Load(data []byte) error {
+	if err := json.Unmarshal(data, op); err != nil {
+		return fmt.Errorf("unmarshal fail: %w", err)
+	}
+	if !json.Valid(op.Patch) {
+		return fmt.Errorf("invalid merge patch: %s", op.Patch)
+	}
+	return nil
+}
+func (op *JSONMergePatchProcessor) Save() []byte {
+	data, _ := json.Marshal(op)
+	return data
+}
+
+func (op *JSONMergePatchProcessor) Process(_ *RealizeContext, before []byte) (after []byte, err error) {
+	return MergePatchJSON(before, op.Patch)
+}
+
+// MergePatchJSON apply JSON Merge Patch on JSON document doc, see RFC 7396.
+// empty doc is taken as null, and keys kept in their original order.
+func MergePatchJSON(doc, patch []byte) ([]byte, error) {
+	var target any
+	if len(bytes.TrimSpace(doc)) > 0 {
+		var err error
+		if target, err = parseJSON(doc); err != nil {
+			return nil, fmt.Errorf("decode document fail: %w", err)
+		}
+	}
+	p, err := parseJSON(patch)
+	if err != nil {
+		return nil, fmt.Errorf("decode merge patch fail: %w", err)
+	}
+	return encodeJSON(mergePatch(target, p))
+}
+
+func mergePatch(target, patch any) any {
+	p, ok := patch.(*jsonObject)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(*jsonObject)
+	if !ok {
+		t = newJSONObject()
+	}
+	for _, key := range p.keys {
+		v := p.values[key]
+		if v == nil {
+			t.del(key)
+			continue
+		}
+		old, _ := t.get(key)
+		t.set(key, mergePatch(old, v))
+	}
+	return t
+}
@@ -1,33 +1,93 @@
 package driver
 
 import (
+	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 )
 
+// RawFunc function to process rule
+type RawFunc func(ctx *RealizeContext, before []byte) (after []byte, err error)
+
+var rawFuncs = struct {
+	sync.RWMutex
+	m map[string]RawFunc
+}{m: make(map[string]RawFunc)}
+
+// RegisterRawFunc register named function
+// RawProcessor with Name set is saved by name and resolved by it on load.
+func RegisterRawFunc(name string, fn RawFunc) {
+	rawFuncs.Lock()
+	defer rawFuncs.Unlock()
+	rawFuncs.m[name] = fn
+}
+
+// NamedRawProcessor create RawProcessor calling function registered by name
+func NamedRawProcessor(name string) (*RawProcessor, error) {
+	op := &RawProcessor{Name: name}
+	if err := op.resolve(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
 var _ Processor = (*RawProcessor)(nil)
 
 type RawProcessor struct {
 	author    string
 	createdAt time.Time
 
+	// Name is the name of registered function, only named RawProcessor can be saved
+	Name string
 	Proc func(ctx *RealizeContext, before []byte) (after []byte, err error)
 }
 
-func (op *RawProcessor) Type() string           { return "" }
-func (op *RawProcessor) Path() string           { return "" }
-func (op *RawProcessor) Author() string         { return op.author }
-func (op *RawProcessor) CreatedAt() time.Time   { return op.createdAt }
-func (op *RawProcessor) Load(data []byte) error { return ErrSerializeNotSupport }
-func (op *RawProcessor) Save() []byte           { return nil }
+func (op *RawProcessor) Type() string         { return "" }
+func (op *RawProcessor) Path() string         { return "" }
+func (op *RawProcessor) Author() string       { return op.author }
+func (op *RawProcessor) CreatedAt() time.Time { return op.createdAt }
+func (op *RawProcessor) Load(data []byte) error {
+	var v struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("unmarshal fail: %w", err)
+	}
+	op.Name = v.Name
+	return op.resolve()
+}
+func (op *RawProcessor) Save() []byte {
+	if op.Name == "" {
+		return nil
+	}
+	data, _ := json.Marshal(map[string]string{"name": op.Name})
+	return data
+}
 func (op *RawProcessor) Process(ctx *RealizeContext, before []byte) (after []byte, err error) {
 	return op.Proc(ctx, before)
 }
 
+// resolve set Proc by registered function name
+func (op *RawProcessor) resolve() error {
+	rawFuncs.RLock()
+	fn, ok := rawFuncs.m[op.Name]
+	rawFuncs.RUnlock()
+	if !ok {
+		return fmt.Errorf("raw function %q not registered", op.Name)
+	}
+	op.Proc = fn
+	return nil
+}
+
 var _ Processor = (*CombinedProcessor)(nil)
 
+// combinedModem modem for processors in CombinedProcessor
+var combinedModem = &RegistryModem{Marshaler: json.Marshal, Unmarshaler: json.Unmarshal}
+
 // CombinedProcessor chains multiple processors into a single one.
 // Processors are applied sequentially: each one's output becomes the next one's input.
+// It is saved as a list of its processors along with their registered kinds.
 type CombinedProcessor struct {
 	procs     []Processor
 	author    string
@@ -43,8 +103,21 @@ func (c *CombinedProcessor) Type() string         { return "combined" }
 func (c *CombinedProcessor) Path() string         { return "" }
 func (c *CombinedProcessor) Author() string       { return c.author }
 func (c *CombinedProcessor) CreatedAt() time.Time { return c.createdAt }
-func (c *CombinedProcessor) Load([]byte) error    { return ErrSerializeNotSupport }
-func (c *CombinedProcessor) Save() []byte         { return nil }
+func (c *CombinedProcessor) Load(data []byte) error {
+	procs, err := combinedModem.Unmarshal(data)
+	if err != nil {
+		return fmt.Errorf("load combined processors fail: %w", err)
+	}
+	c.procs = procs
+	return nil
+}
+func (c *CombinedProcessor) Save() []byte {
+	data, err := combinedModem.Marshal(c.procs...)
+	if err != nil {
+		return nil
+	}
+	return data
+}
 func (c *CombinedProcessor) Process(rc *RealizeContext, before []byte) ([]byte, error) {
 	var err error
 	for _, proc := range c.procs {
@@ -0,0 +1,120 @@
+package driver
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// DefaultRegistry is the global processor registry
+var DefaultRegistry = NewRegistry(nil)
+
+func init() {
+	RegisterProcessor("json", func() Processor { return new(JSONProcessor) })
+	RegisterProcessor("json_patch", func() Processor { return new(JSONPatchProcessor) })
+	RegisterProcessor("json_merge_patch", func() Processor { return new(JSONMergePatchProcessor) })
+	RegisterProcessor("yaml", func() Processor { return new(YAMLProcessor) })
+	RegisterProcessor("toml", func() Processor { return new(TOMLProcessor) })
+	RegisterProcessor("xml", func() Processor { return new(XMLProcessor) })
+	RegisterProcessor("curl", func() Processor { return new(CURLProcessor) })
+	RegisterProcessor("raw", func() Processor { return new(RawProcessor) })
+	RegisterProcessor("combined", func() Processor { return new(CombinedProcessor) })
+	RegisterProcessor("merge", func() Processor { return new(MergeProcessor) })
+}
+
+// RegisterProcessor register processor constructor by kind to DefaultRegistry
+func RegisterProcessor(kind string, newProcessor func() Processor) {
+	DefaultRegistry.Register(kind, newProcessor)
+}
+
+// NewProcessor create an empty processor by kind registered in DefaultRegistry
+func NewProcessor(kind string) (Processor, error) { return DefaultRegistry.New(kind) }
+
+// Registry maps processor kind to its constructor,
+// so processors of different types can be saved and loaded by kind.
+type Registry struct {
+	parent *Registry
+
+	mu     sync.RWMutex
+	byKind map[string]func() Processor
+	byType map[reflect.Type]string
+}
+
+// NewRegistry create a processor registry
+// kinds not found in registry are looked up in parent if not nil,
+// e.g. NewRegistry(DefaultRegistry) for a driver-scoped registry.
+func NewRegistry(parent *Registry) *Registry {
+	return &Registry{
+		parent: parent,
+		byKind: make(map[string]func() Processor),
+		byType: make(map[reflect.Type]string),
+	}
+}
+
+// Register register processor constructor by kind
+// processor type is bound to kind by the instance newProcessor returns.
+func (r *Registry) Register(kind string, newProcessor func() Processor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byKind[kind] = newProcessor
+	r.byType[reflect.TypeOf(newProcessor())] = kind
+}
+
+// New create an empty processor by kind
+func (r *Registry) New(kind string) (Processor, error) {
+	r.mu.RLock()
+	newProcessor, ok := r.byKind[kind]
+	r.mu.RUnlock()
+	if ok {
+		return newProcessor(), nil
+	}
+	if r.parent != nil {
+		return r.parent.New(kind)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownProcessor, kind)
+}
+
+// KindOf return the kind processor type registered as
+func (r *Registry) KindOf(proc Processor) (string, bool) {
+	r.mu.RLock()
+	kind, ok := r.byType[reflect.TypeOf(proc)]
+	r.mu.RUnlock()
+	if ok {
+		return kind, true
+	}
+	if r.parent != nil {
+		return r.parent.KindOf(proc)
+	}
+	return "", false
+}
+
+var drivers = struct {
+	sync.RWMutex
+	m map[string]func() Driver
+}{m: map[string]func() Driver{
+	"json":  func() Driver { return NewJSONDriver() },
+	"yaml":  func() Driver { return NewYAMLDriver() },
+	"toml":  func() Driver { return NewTOMLDriver() },
+	"xml":   func() Driver { return NewXMLDriver() },
+	"tile":  func() Driver { return NewTileDriver() },
+	"dummy": func() Driver { return NewDummyDriver() },
+}}
+
+// RegisterDriver register driver constructor by name
+// registered name can be used to build trees from spec
+func RegisterDriver(name string, newDriver func() Driver) {
+	drivers.Lock()
+	defer drivers.Unlock()
+	drivers.m[name] = newDriver
+}
+
+// NewDriver create a driver by registered name
+func NewDriver(name string) (Driver, error) {
+	drivers.RLock()
+	newDriver, ok := drivers.m[name]
+	drivers.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, name)
+	}
+	return newDriver(), nil
+}
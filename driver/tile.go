@@ -8,7 +8,8 @@ func NewTileDriver() *TileDriver {
 	return &TileDriver{
 		PathParser: SlashPathParser,
 		Realizer:   new(StdRealizer),
-		Modem: &GeneralModem[*RawProcessor]{
+		Modem: &RegistryModem{
+			Default:     "raw",
 			Marshaler:   json.Marshal,
 			Unmarshaler: json.Unmarshal,
 		},
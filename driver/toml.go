@@ -16,8 +16,9 @@ var _ Driver = (*TOMLDriver)(nil)
 func NewTOMLDriver() *TOMLDriver {
 	return &TOMLDriver{
 		PathParser: SlashPathParser,
-		Realizer:   new(StdRealizer),
-		Modem: &GeneralModem[*TOMLProcessor]{
+		Realizer:   new(TOMLRealizer),
+		Modem: &RegistryModem{
+			Default:     "toml",
 			Marshaler:   json.Marshal,
 			Unmarshaler: json.Unmarshal,
 		},
@@ -70,36 +71,56 @@ func (op *TOMLProcessor) Save() []byte {
 }
 
 func (op *TOMLProcessor) Process(_ *RealizeContext, before []byte) (after []byte, err error) {
-	m := make(map[string]any)
-	if len(before) > 0 {
-		if err := toml.Unmarshal(before, &m); err != nil {
-			return nil, fmt.Errorf("unmarshal toml fail: %w", err)
-		}
-	}
+	return tomlCodec.process(op, before)
+}
 
+func (op *TOMLProcessor) apply(m map[string]any) error {
 	segments := splitTOMLPath(op.TOMLPath)
 
 	switch op.T {
-	case "create", "append":
-		err = tomlCreate(m, segments, op.V)
+	case "create":
+		return tomlCreate(m, segments, op.V)
+	case "append":
+		return tomlAppend(m, segments, op.V)
 	case "set":
-		err = tomlSet(m, segments, op.V)
+		return tomlSet(m, segments, op.V)
 	case "replace":
-		err = tomlReplace(m, segments, op.V)
+		return tomlReplace(m, segments, op.V)
 	case "delete":
-		err = tomlDelete(m, segments)
+		return tomlDelete(m, segments)
 	default:
-		return nil, fmt.Errorf("unknown Processor type: %s", op.T)
-	}
-	if err != nil {
-		return nil, err
+		return fmt.Errorf("unknown Processor type: %s", op.T)
 	}
+}
 
-	result, err := toml.Marshal(&m)
-	if err != nil {
-		return nil, fmt.Errorf("marshal toml fail: %w", err)
-	}
-	return result, nil
+var _ Realizer = (*TOMLRealizer)(nil)
+
+// TOMLRealizer realize toml rule, parses document once for consecutive TOMLProcessors
+type TOMLRealizer struct{}
+
+// Realize calculate rule
+func (r *TOMLRealizer) Realize(rc *RealizeContext, rule []byte, procs ...Processor) ([]byte, error) {
+	return tomlCodec.realize(rc, rule, procs...)
+}
+
+// tomlCodec toml document model codec
+var tomlCodec = modelCodec[map[string]any]{
+	parse: func(data []byte) (map[string]any, error) {
+		m := make(map[string]any)
+		if len(data) > 0 {
+			if err := toml.Unmarshal(data, &m); err != nil {
+				return nil, fmt.Errorf("unmarshal toml fail: %w", err)
+			}
+		}
+		return m, nil
+	},
+	serialize: func(m map[string]any) ([]byte, error) {
+		result, err := toml.Marshal(&m)
+		if err != nil {
+			return nil, fmt.Errorf("marshal toml fail: %w", err)
+		}
+		return result, nil
+	},
 }
 
 // --- path helpers ---
@@ -177,28 +198,43 @@ func tomlCreate(m map[string]any, segments []string, value []byte) error {
 		return err
 	}
 
+	if _, exists := parent[lastKey]; exists {
+		return fmt.Errorf("key already exists: %s", lastKey)
+	}
+
 	v, err := parseTOMLValue(value)
 	if err != nil {
 		return err
 	}
 
-	existing, exists := parent[lastKey]
-	if !exists {
-		parent[lastKey] = v
-		return nil
+	parent[lastKey] = v
+	return nil
+}
+
+func tomlAppend(m map[string]any, segments []string, value []byte) error {
+	parent, lastKey, err := tomlNavigateOrCreate(m, segments)
+	if err != nil {
+		return err
+	}
+
+	v, err := parseTOMLValue(value)
+	if err != nil {
+		return err
 	}
 
-	// If existing value is a slice, append to it
-	if slice, ok := existing.([]any); ok {
-		if vs, ok := v.([]any); ok {
-			parent[lastKey] = append(slice, vs...)
-		} else {
-			parent[lastKey] = append(slice, v)
+	var slice []any
+	if existing, exists := parent[lastKey]; exists {
+		var ok bool
+		if slice, ok = existing.([]any); !ok {
+			return fmt.Errorf("key %s is not an array", lastKey)
 		}
-		return nil
 	}
 
-	parent[lastKey] = v
+	if vs, ok := v.([]any); ok {
+		parent[lastKey] = append(slice, vs...)
+	} else {
+		parent[lastKey] = append(slice, v)
+	}
 	return nil
 }
 
@@ -223,6 +259,10 @@ func tomlReplace(m map[string]any, segments []string, value []byte) error {
 		return err
 	}
 
+	if _, ok := parent[lastKey]; !ok {
+		return fmt.Errorf("key not found: %s", lastKey)
+	}
+
 	v, err := parseTOMLValue(value)
 	if err != nil {
 		return err
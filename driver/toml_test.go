@@ -173,3 +173,39 @@ func TestTOMLProcessor_MarshalUnmarshal(t *testing.T) {
 		t.Errorf("expected value %s, got %s", original.V, restored.V)
 	}
 }
+
+func TestTOMLRealizer(t *testing.T) {
+	var seen string
+	procs := []driver.Processor{
+		&driver.TOMLProcessor{T: "create", TOMLPath: "server.host", V: []byte(`"localhost"`)},
+		&driver.TOMLProcessor{T: "create", TOMLPath: "server.port", V: []byte("8080")},
+		&driver.RawProcessor{Proc: func(_ *driver.RealizeContext, before []byte) ([]byte, error) {
+			seen = string(before)
+			return before, nil
+		}},
+		&driver.TOMLProcessor{T: "set", TOMLPath: "server.port", V: []byte("9090")},
+		&driver.TOMLProcessor{T: "delete", TOMLPath: "server.host"},
+	}
+
+	want, err := new(driver.StdRealizer).Realize(nil, nil, procs...)
+	if err != nil {
+		t.Errorf("std realize fail: %s", err)
+		return
+	}
+	got, err := driver.NewTOMLDriver().Realize(nil, nil, procs...)
+	if err != nil {
+		t.Errorf("realize fail: %s", err)
+		return
+	}
+	if string(got) != string(want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if !strings.Contains(seen, "localhost") || !strings.Contains(seen, "8080") {
+		t.Errorf("expected raw processor to see serialized document, got: %s", seen)
+	}
+
+	_, err = driver.NewTOMLDriver().Realize(nil, nil, &driver.TOMLProcessor{T: "delete", TOMLPath: "missing"})
+	if err == nil {
+		t.Errorf("expected error deleting missing key")
+	}
+}
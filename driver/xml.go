@@ -16,8 +16,9 @@ var _ Driver = (*XMLDriver)(nil)
 func NewXMLDriver() *XMLDriver {
 	return &XMLDriver{
 		PathParser: SlashPathParser,
-		Realizer:   new(StdRealizer),
-		Modem: &GeneralModem[*XMLProcessor]{
+		Realizer:   new(XMLRealizer),
+		Modem: &RegistryModem{
+			Default:     "xml",
 			Marshaler:   json.Marshal,
 			Unmarshaler: json.Unmarshal,
 		},
@@ -70,33 +71,54 @@ func (op *XMLProcessor) Save() []byte {
 }
 
 func (op *XMLProcessor) Process(_ *RealizeContext, before []byte) (after []byte, err error) {
-	if len(before) == 0 {
-		before = []byte(`<root/>`)
-	}
-	root, err := xmlToNodes(before)
-	if err != nil {
-		return nil, fmt.Errorf("parse xml fail: %w", err)
-	}
+	return xmlCodec.process(op, before)
+}
 
+func (op *XMLProcessor) apply(root *xmlNode) error {
 	segments := splitXMLPath(op.XMLPath)
 
 	switch op.T {
-	case "create", "append":
-		err = xmlCreate(root, segments, op.V)
+	case "create":
+		if _, err := xmlNavigate(root, segments); err == nil {
+			return fmt.Errorf("element already exists: %s", op.XMLPath)
+		}
+		return xmlCreate(root, segments, op.V)
+	case "append":
+		return xmlCreate(root, segments, op.V)
 	case "set":
-		err = xmlSet(root, segments, op.V)
+		return xmlSet(root, segments, op.V)
 	case "replace":
-		err = xmlReplace(root, segments, op.V)
+		return xmlReplace(root, segments, op.V)
 	case "delete":
-		err = xmlDelete(root, segments)
+		return xmlDelete(root, segments)
 	default:
-		return nil, fmt.Errorf("unknown Processor type: %s", op.T)
-	}
-	if err != nil {
-		return nil, err
+		return fmt.Errorf("unknown Processor type: %s", op.T)
 	}
+}
+
+var _ Realizer = (*XMLRealizer)(nil)
+
+// XMLRealizer realize xml rule, parses document once for consecutive XMLProcessors
+type XMLRealizer struct{}
+
+// Realize calculate rule
+func (r *XMLRealizer) Realize(rc *RealizeContext, rule []byte, procs ...Processor) ([]byte, error) {
+	return xmlCodec.realize(rc, rule, procs...)
+}
 
-	return nodesToXML(root)
+// xmlCodec xml document model codec
+var xmlCodec = modelCodec[*xmlNode]{
+	parse: func(data []byte) (*xmlNode, error) {
+		if len(data) == 0 {
+			data = []byte(`<root/>`)
+		}
+		root, err := xmlToNodes(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse xml fail: %w", err)
+		}
+		return root, nil
+	},
+	serialize: nodesToXML,
 }
 
 // --- internal XML node tree ---
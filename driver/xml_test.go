@@ -171,3 +171,34 @@ func TestXMLProcessor_MarshalUnmarshal(t *testing.T) {
 		t.Errorf("expected value %s, got %s", original.V, restored.V)
 	}
 }
+
+func TestXMLRealizer(t *testing.T) {
+	var seen string
+	procs := []driver.Processor{
+		&driver.XMLProcessor{T: "create", XMLPath: "root/name/first", V: []byte("river")},
+		&driver.XMLProcessor{T: "create", XMLPath: "root/items/item", V: []byte("a")},
+		&driver.RawProcessor{Proc: func(_ *driver.RealizeContext, before []byte) ([]byte, error) {
+			seen = string(before)
+			return before, nil
+		}},
+		&driver.XMLProcessor{T: "set", XMLPath: "root/name/first", V: []byte("River")},
+		&driver.XMLProcessor{T: "replace", XMLPath: "root/items", V: []byte("<item>c</item><item>d</item>")},
+	}
+
+	want, err := new(driver.StdRealizer).Realize(nil, nil, procs...)
+	if err != nil {
+		t.Errorf("std realize fail: %s", err)
+		return
+	}
+	got, err := driver.NewXMLDriver().Realize(nil, nil, procs...)
+	if err != nil {
+		t.Errorf("realize fail: %s", err)
+		return
+	}
+	if string(got) != string(want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if !strings.Contains(seen, "<first>river</first>") {
+		t.Errorf("expected raw processor to see serialized document, got: %s", seen)
+	}
+}
@@ -1,8 +1,14 @@
 package driver
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 var _ Driver = (*YAMLDriver)(nil)
@@ -10,8 +16,9 @@ var _ Driver = (*YAMLDriver)(nil)
 func NewYAMLDriver() *YAMLDriver {
 	return &YAMLDriver{
 		PathParser: SlashPathParser,
-		Realizer:   new(StdRealizer),
-		Modem: &GeneralModem[*YAMLProcessor]{
+		Realizer:   new(YAMLRealizer),
+		Modem: &RegistryModem{
+			Default:     "yaml",
 			Marshaler:   json.Marshal,
 			Unmarshaler: json.Unmarshal,
 		},
@@ -29,28 +36,417 @@ func (YAMLDriver) Name() string { return "yaml" }
 
 var _ Processor = (*YAMLProcessor)(nil)
 
+// YAMLProcessor is a Processor for YAML type rule tree
+// it works on yaml nodes, so comments, key order and anchors in document are kept
 type YAMLProcessor struct {
+	// P is the target path of the Processor
+	P string `json:"path"`
+
+	// T is the type of the Processor
 	T string `json:"type"`
+	// YAMLPath is the yaml key path of the Processor,
+	// dot-separated keys with sequence indexes, like "servers[0].host"
+	YAMLPath string `json:"yaml_path"`
+	// V is the value of the Processor in yaml
 	V []byte `json:"value"`
+
+	// A is the author of the Processor
+	A string `json:"author"`
+	// C is the create time of the Processor
+	C time.Time `json:"created_at"`
 }
 
-func (op *YAMLProcessor) Type() string           { return op.T }
-func (op *YAMLProcessor) Path() string           { return "" }
-func (op *YAMLProcessor) Author() string         { return "" }
-func (op *YAMLProcessor) CreatedAt() time.Time   { return time.Now() }
-func (op *YAMLProcessor) Load(data []byte) error { return json.Unmarshal(data, op) }
+func (op *YAMLProcessor) Type() string         { return op.T }
+func (op *YAMLProcessor) Path() string         { return op.P }
+func (op *YAMLProcessor) Author() string       { return op.A }
+func (op *YAMLProcessor) CreatedAt() time.Time { return op.C }
+func (op *YAMLProcessor) Load(data []byte) error {
+	if err := json.Unmarshal(data, op); err != nil {
+		return fmt.Errorf("unmarshal fail: %w", err)
+	}
+	return nil
+}
 func (op *YAMLProcessor) Save() []byte {
 	data, _ := json.Marshal(op)
 	return data
 }
+
 func (op *YAMLProcessor) Process(_ *RealizeContext, before []byte) (after []byte, err error) {
-	// var result any
-	// if err := yaml.Unmarshal([]byte(before), &result); err != nil {
-	// 	return "", fmt.Errorf("unmarshal yaml fail: %w", err)
-	// }
+	return yamlCodec.process(op, before)
+}
+
+func (op *YAMLProcessor) apply(doc *yaml.Node) error {
+	segments, err := splitYAMLPath(op.YAMLPath)
+	if err != nil {
+		return err
+	}
+
 	switch op.T {
+	case "create":
+		err = yamlCreate(doc, segments, op.V)
 	case "append":
-		return append(before, op.T...), nil
+		err = yamlAppend(doc, segments, op.V)
+	case "set":
+		err = yamlSet(doc, segments, op.V)
+	case "replace":
+		err = yamlReplace(doc, segments, op.V)
+	case "delete":
+		err = yamlDelete(doc, segments)
+	default:
+		return fmt.Errorf("unknown Processor type: %s", op.T)
+	}
+	if err != nil {
+		return err
+	}
+	return yamlCheckAliases(doc)
+}
+
+var _ Realizer = (*YAMLRealizer)(nil)
+
+// YAMLRealizer realize yaml rule, parses document once for consecutive YAMLProcessors
+type YAMLRealizer struct{}
+
+// Realize calculate rule
+func (r *YAMLRealizer) Realize(rc *RealizeContext, rule []byte, procs ...Processor) ([]byte, error) {
+	return yamlCodec.realize(rc, rule, procs...)
+}
+
+// yamlCodec yaml document model codec, model is the document node
+var yamlCodec = modelCodec[*yaml.Node]{
+	parse: func(data []byte) (*yaml.Node, error) {
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("unmarshal yaml fail: %w", err)
+		}
+		if doc.Kind != yaml.DocumentNode { // empty document
+			doc = yaml.Node{Kind: yaml.DocumentNode, HeadComment: doc.HeadComment}
+		}
+		if len(doc.Content) == 0 {
+			doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+		}
+		return &doc, nil
+	},
+	serialize: func(doc *yaml.Node) ([]byte, error) {
+		untagMerge(doc)
+
+		buf := new(bytes.Buffer)
+		enc := yaml.NewEncoder(buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("marshal yaml fail: %w", err)
+		}
+		if err := enc.Close(); err != nil {
+			return nil, fmt.Errorf("marshal yaml fail: %w", err)
+		}
+		return buf.Bytes(), nil
+	},
+}
+
+// untagMerge clear tag of merge keys, or encoder writes them as "!!merge <<"
+func untagMerge(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!merge" {
+		node.Tag = ""
+	}
+	for _, child := range node.Content {
+		untagMerge(child)
+	}
+}
+
+// --- path helpers ---
+
+// yamlSegment a segment of yaml path, mapping key or sequence index
+type yamlSegment struct {
+	key   string
+	index int // -1 for mapping key
+}
+
+func (s yamlSegment) String() string {
+	if s.index < 0 {
+		return s.key
+	}
+	return "[" + strconv.Itoa(s.index) + "]"
+}
+
+// splitYAMLPath split path like "a.b[0][1].c" into segments
+func splitYAMLPath(path string) (segments []yamlSegment, err error) {
+	path = strings.Trim(path, ".")
+	if path == "" {
+		return nil, nil
+	}
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			key, part = part[:i], part[i:]
+		} else {
+			part = ""
+		}
+		if key != "" {
+			segments = append(segments, yamlSegment{key: key, index: -1})
+		}
+		for part != "" {
+			end := strings.IndexByte(part, ']')
+			if part[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("invalid yaml path: %s", path)
+			}
+			index, err := strconv.Atoi(part[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index in yaml path: %s", path)
+			}
+			segments = append(segments, yamlSegment{index: index})
+			part = part[end+1:]
+		}
+		if key == "" && len(segments) == 0 {
+			return nil, fmt.Errorf("invalid yaml path: %s", path)
+		}
+	}
+	return segments, nil
+}
+
+// yamlChild return position of child at seg in node content, -1 if not found
+func yamlChild(node *yaml.Node, seg yamlSegment) (int, error) {
+	switch {
+	case seg.index < 0 && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == seg.key {
+				return i + 1, nil
+			}
+		}
+		return -1, nil
+	case seg.index >= 0 && node.Kind == yaml.SequenceNode:
+		if seg.index < len(node.Content) {
+			return seg.index, nil
+		}
+		return -1, nil
+	case node.Kind == yaml.AliasNode:
+		return -1, fmt.Errorf("%s is under an alias", seg)
+	case seg.index < 0:
+		return -1, fmt.Errorf("parent of %s is not a mapping", seg)
+	default:
+		return -1, fmt.Errorf("parent of %s is not a sequence", seg)
+	}
+}
+
+// yamlNavigate navigates to the parent node and returns it along with the final segment.
+func yamlNavigate(doc *yaml.Node, segments []yamlSegment) (*yaml.Node, yamlSegment, error) {
+	if len(segments) == 0 {
+		return nil, yamlSegment{}, fmt.Errorf("empty yaml path")
+	}
+	cur := doc.Content[0]
+	for _, seg := range segments[:len(segments)-1] {
+		i, err := yamlChild(cur, seg)
+		if err != nil {
+			return nil, yamlSegment{}, err
+		}
+		if i < 0 {
+			return nil, yamlSegment{}, fmt.Errorf("key not found: %s", seg)
+		}
+		cur = cur.Content[i]
+	}
+	return cur, segments[len(segments)-1], nil
+}
+
+// yamlNavigateOrCreate navigates to the parent node, creating intermediate mappings or sequences as needed.
+func yamlNavigateOrCreate(doc *yaml.Node, segments []yamlSegment) (*yaml.Node, yamlSegment, error) {
+	if len(segments) == 0 {
+		return nil, yamlSegment{}, fmt.Errorf("empty yaml path")
+	}
+	cur := doc.Content[0]
+	for n, seg := range segments[:len(segments)-1] {
+		i, err := yamlChild(cur, seg)
+		if err != nil {
+			return nil, yamlSegment{}, err
+		}
+		if i >= 0 {
+			cur = cur.Content[i]
+			continue
+		}
+
+		sub := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if segments[n+1].index >= 0 {
+			sub = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+		if err := yamlPut(cur, seg, sub); err != nil {
+			return nil, yamlSegment{}, err
+		}
+		cur = sub
+	}
+	return cur, segments[len(segments)-1], nil
+}
+
+// yamlPut put value at seg of parent, replaced value keeps its comments and anchor
+func yamlPut(parent *yaml.Node, seg yamlSegment, v *yaml.Node) error {
+	i, err := yamlChild(parent, seg)
+	if err != nil {
+		return err
+	}
+	if i >= 0 {
+		old := parent.Content[i]
+		if v.HeadComment == "" && v.LineComment == "" && v.FootComment == "" {
+			v.HeadComment, v.LineComment, v.FootComment = old.HeadComment, old.LineComment, old.FootComment
+		}
+		if v.Anchor == "" && v.Kind != yaml.AliasNode {
+			v.Anchor = old.Anchor
+		}
+		parent.Content[i] = v
+		return nil
+	}
+
+	if seg.index < 0 {
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: seg.key}, v)
+		return nil
+	}
+	if seg.index != len(parent.Content) {
+		return fmt.Errorf("index out of range: %s", seg)
+	}
+	parent.Content = append(parent.Content, v)
+	return nil
+}
+
+// yamlCheckAliases check every alias in document refers to an anchor defined before it,
+// so operations never leave an alias to a replaced or deleted anchor behind
+func yamlCheckAliases(doc *yaml.Node) error {
+	anchors := make(map[string]bool)
+	var check func(node *yaml.Node) error
+	check = func(node *yaml.Node) error {
+		if node.Kind == yaml.AliasNode && !anchors[node.Value] {
+			return fmt.Errorf("alias *%s refers to anchor not defined before it", node.Value)
+		}
+		if node.Anchor != "" {
+			anchors[node.Anchor] = true
+		}
+		for _, child := range node.Content {
+			if err := check(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(doc)
+}
+
+// parseYAMLValue unmarshals a yaml value from bytes into a yaml node.
+func parseYAMLValue(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse yaml value fail: %w", err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+	return doc.Content[0], nil
+}
+
+// --- operations ---
+
+func yamlCreate(doc *yaml.Node, segments []yamlSegment, value []byte) error {
+	parent, last, err := yamlNavigateOrCreate(doc, segments)
+	if err != nil {
+		return err
+	}
+
+	if i, err := yamlChild(parent, last); err != nil {
+		return err
+	} else if i >= 0 {
+		return fmt.Errorf("key already exists: %s", last)
+	}
+
+	v, err := parseYAMLValue(value)
+	if err != nil {
+		return err
+	}
+
+	return yamlPut(parent, last, v)
+}
+
+func yamlAppend(doc *yaml.Node, segments []yamlSegment, value []byte) error {
+	parent, last, err := yamlNavigateOrCreate(doc, segments)
+	if err != nil {
+		return err
+	}
+
+	v, err := parseYAMLValue(value)
+	if err != nil {
+		return err
+	}
+
+	i, err := yamlChild(parent, last)
+	if err != nil {
+		return err
+	}
+	var seq *yaml.Node
+	switch {
+	case i < 0:
+		seq = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if err := yamlPut(parent, last, seq); err != nil {
+			return err
+		}
+	case parent.Content[i].Kind != yaml.SequenceNode:
+		return fmt.Errorf("key %s is not a sequence", last)
+	default:
+		seq = parent.Content[i]
+	}
+
+	if v.Kind == yaml.SequenceNode {
+		seq.Content = append(seq.Content, v.Content...)
+	} else {
+		seq.Content = append(seq.Content, v)
+	}
+	return nil
+}
+
+func yamlSet(doc *yaml.Node, segments []yamlSegment, value []byte) error {
+	parent, last, err := yamlNavigateOrCreate(doc, segments)
+	if err != nil {
+		return err
+	}
+
+	v, err := parseYAMLValue(value)
+	if err != nil {
+		return err
+	}
+
+	return yamlPut(parent, last, v)
+}
+
+func yamlReplace(doc *yaml.Node, segments []yamlSegment, value []byte) error {
+	parent, last, err := yamlNavigate(doc, segments)
+	if err != nil {
+		return err
+	}
+
+	i, err := yamlChild(parent, last)
+	if err != nil {
+		return err
+	}
+	if i < 0 {
+		return fmt.Errorf("key not found: %s", last)
+	}
+
+	v, err := parseYAMLValue(value)
+	if err != nil {
+		return err
+	}
+
+	return yamlPut(parent, last, v)
+}
+
+func yamlDelete(doc *yaml.Node, segments []yamlSegment) error {
+	parent, last, err := yamlNavigate(doc, segments)
+	if err != nil {
+		return err
+	}
+
+	i, err := yamlChild(parent, last)
+	if err != nil {
+		return err
+	}
+	switch {
+	case i < 0:
+		return fmt.Errorf("key not found: %s", last)
+	case parent.Kind == yaml.MappingNode:
+		parent.Content = append(parent.Content[:i-1], parent.Content[i+1:]...)
+	default:
+		parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
 	}
-	return before, nil
+	return nil
 }
@@ -0,0 +1,166 @@
+package driver_test
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tr1v3r/ivy/driver"
+)
+
+func TestYAMLDriver(t *testing.T) {
+	d := driver.NewYAMLDriver()
+
+	data, err := d.Marshal([]driver.Processor{
+		&driver.YAMLProcessor{T: "create", YAMLPath: "server.host", V: []byte(`localhost`)},
+		&driver.YAMLProcessor{T: "create", YAMLPath: "server.port", V: []byte("8080")},
+		&driver.YAMLProcessor{T: "set", YAMLPath: "server.port", V: []byte("9090")},
+		&driver.YAMLProcessor{T: "create", YAMLPath: "server.tags", V: []byte(`[a, b]`)},
+		&driver.YAMLProcessor{T: "append", YAMLPath: "server.tags", V: []byte(`c`)},
+		&driver.YAMLProcessor{T: "delete", YAMLPath: "server.host"},
+		&driver.YAMLProcessor{T: "replace", YAMLPath: "server.tags[0]", V: []byte(`x`)},
+	}...)
+	if err != nil {
+		t.Errorf("marshal fail: %s", err)
+		return
+	}
+
+	ops, err := d.Unmarshal(data)
+	if err != nil {
+		t.Errorf("unmarshal fail: %s", err)
+		return
+	}
+
+	rule, err := d.Realize(nil, nil, ops...)
+	if err != nil {
+		t.Errorf("realize fail: %s", err)
+		return
+	}
+	t.Logf("got result:\n%s", rule)
+
+	if expected := "server:\n  port: 9090\n  tags: [x, b, c]\n"; string(rule) != expected {
+		t.Errorf("expected %q, got %q", expected, rule)
+	}
+}
+
+func TestYAMLProcessor_Path(t *testing.T) {
+	before := []byte("servers:\n  - host: a\n  - host: b\n")
+
+	op := &driver.YAMLProcessor{T: "set", YAMLPath: "servers[1].port", V: []byte("80")}
+	result, err := op.Process(nil, before)
+	if err != nil {
+		t.Errorf("Process fail: %s", err)
+		return
+	}
+	if expected := "servers:\n  - host: a\n  - host: b\n    port: 80\n"; string(result) != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+
+	op = &driver.YAMLProcessor{T: "set", YAMLPath: "servers[3].host", V: []byte("c")}
+	if _, err := op.Process(nil, before); err == nil {
+		t.Errorf("expected error for index out of range")
+	}
+
+	op = &driver.YAMLProcessor{T: "set", YAMLPath: "servers.host", V: []byte("c")}
+	if _, err := op.Process(nil, before); err == nil {
+		t.Errorf("expected error for key on sequence")
+	}
+}
+
+func TestYAMLProcessor_Preserve(t *testing.T) {
+	before := []byte(`# service config
+name: svc # service name
+defaults: &defaults
+  timeout: 3
+zone: cn
+db:
+  <<: *defaults
+  host: db.local
+`)
+
+	ops := []driver.Processor{
+		&driver.YAMLProcessor{T: "set", YAMLPath: "name", V: []byte("api")},
+		&driver.YAMLProcessor{T: "set", YAMLPath: "db.host", V: []byte("db.prod")},
+		&driver.YAMLProcessor{T: "delete", YAMLPath: "zone"},
+	}
+	result, err := driver.NewYAMLDriver().Realize(nil, before, ops...)
+	if err != nil {
+		t.Errorf("realize fail: %s", err)
+		return
+	}
+
+	expected := `# service config
+name: api # service name
+defaults: &defaults
+  timeout: 3
+db:
+  <<: *defaults
+  host: db.prod
+`
+	if string(result) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, result)
+	}
+}
+
+func TestYAMLProcessor_Replace(t *testing.T) {
+	op := &driver.YAMLProcessor{T: "replace", YAMLPath: "missing", V: []byte("1")}
+	if _, err := op.Process(nil, []byte("key: v\n")); err == nil {
+		t.Errorf("expected error replacing missing key")
+	}
+
+	op = &driver.YAMLProcessor{T: "replace", YAMLPath: "key", V: []byte("{a: 1}")}
+	result, err := op.Process(nil, []byte("key: v\n"))
+	if err != nil {
+		t.Errorf("Process fail: %s", err)
+		return
+	}
+	if !strings.Contains(string(result), "key: {a: 1}") {
+		t.Errorf("expected key replaced, got: %s", result)
+	}
+}
+
+func TestYAMLProcessor_Anchor(t *testing.T) {
+	doc := []byte("base: &b {x: 1}\nuse: *b\n")
+
+	op := &driver.YAMLProcessor{T: "replace", YAMLPath: "base", V: []byte("{x: 2}")}
+	result, err := op.Process(nil, doc)
+	if err != nil {
+		t.Errorf("Process fail: %s", err)
+		return
+	}
+	var got map[string]map[string]int
+	if err := yaml.Unmarshal(result, &got); err != nil {
+		t.Errorf("replaced document unmarshal fail: %s\n%s", err, result)
+		return
+	}
+	if got["use"]["x"] != 2 {
+		t.Errorf("expected alias refers to replaced value, got: %s", result)
+	}
+
+	var testcases = []struct {
+		Doc     string
+		Path    string
+		WantErr bool
+	}{
+		{"base: &b {x: 1}\nuse: *b\n", "base", true},
+		{"base: {x: &x 1}\nuse: *x\n", "base", true},
+		{"base: &b {x: 1}\nuse: *b\n", "use", false},
+	}
+	for _, item := range testcases {
+		op = &driver.YAMLProcessor{T: "delete", YAMLPath: item.Path}
+		result, err := op.Process(nil, []byte(item.Doc))
+		if item.WantErr && err == nil {
+			t.Errorf("expected error deleting %s from %q, got: %s", item.Path, item.Doc, result)
+		} else if !item.WantErr && err != nil {
+			t.Errorf("delete %s from %q fail: %s", item.Path, item.Doc, err)
+		}
+	}
+}
+
+func TestYAMLProcessor_UnknownType(t *testing.T) {
+	op := &driver.YAMLProcessor{T: "unknown", YAMLPath: "key"}
+	if _, err := op.Process(nil, nil); err == nil {
+		t.Errorf("expected error for unknown type")
+	}
+}
@@ -1,9 +1,13 @@
 package ivy
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -86,10 +90,10 @@ func InitForest(t *testing.T) Forest {
 					&driver.JSONProcessor{T: "create", JSONPath: "name", V: []byte("root")},
 				}},
 				{path: "/a/b/c/d", processors: []driver.Processor{
-					&driver.JSONProcessor{T: "create", JSONPath: "info.path", V: []byte("path:a/b/c/d")},
+					&driver.JSONProcessor{T: "replace", JSONPath: "info.path", V: []byte("path:a/b/c/d")},
 				}},
 				{path: "/a/b/c", processors: []driver.Processor{
-					&driver.JSONProcessor{T: "create", JSONPath: "info.path", V: []byte("path:a/b/c")},
+					&driver.JSONProcessor{T: "replace", JSONPath: "info.path", V: []byte("path:a/b/c")},
 				}},
 				{path: "/a/b", processors: []driver.Processor{
 					&driver.JSONProcessor{T: "create", JSONPath: "info.path", V: []byte("path:a/b")},
@@ -98,7 +102,7 @@ func InitForest(t *testing.T) Forest {
 					&driver.JSONProcessor{T: "create", JSONPath: "info.path", V: []byte("path:x/y/z")},
 				}},
 				{path: "/a/b/m", processors: []driver.Processor{
-					&driver.JSONProcessor{T: "create", JSONPath: "info.path", V: []byte("path:a/b/m")},
+					&driver.JSONProcessor{T: "replace", JSONPath: "info.path", V: []byte("path:a/b/m")},
 				}},
 			}
 			tree, err := NewTree(&struct {
@@ -457,3 +461,1183 @@ func TestCombineProcessor_WithFallback(t *testing.T) {
 func containsJSON(s, sub string) bool {
 	return strings.Contains(s, sub)
 }
+
+func TestTree_ParamAndWildcard(t *testing.T) {
+	paramProc := &driver.RawProcessor{
+		Proc: func(rc *driver.RealizeContext, before []byte) ([]byte, error) {
+			return fmt.Appendf(nil, `{"id":%q,"file":%q}`, rc.Params["id"], rc.Params["file"]), nil
+		},
+	}
+
+	tree, err := NewLazyInstantTree(
+		&struct {
+			driver.Modem
+			driver.PathParser
+			driver.StdRealizer
+			driver.DummyDriver
+		}{Modem: driver.DummyModem, PathParser: driver.SlashPathParser},
+		"param_test", `{}`,
+		NewDirective("/users/:id/profile", paramProc),
+		NewDirective("/users/admin", &driver.JSONProcessor{T: "create", JSONPath: "admin", V: []byte("yes")}),
+		NewDirective("/assets/*file", paramProc),
+	)
+	if err != nil {
+		t.Fatalf("build tree fail: %s", err)
+	}
+
+	var testcases = []struct {
+		path     string
+		expected string
+	}{
+		{"/users/42/profile", `{"id":"42","file":""}`},
+		{"/users/admin", `{"admin":"yes"}`},
+		{"/assets/css/site.css", `{"id":"","file":"css/site.css"}`},
+	}
+	for _, item := range testcases {
+		result, err := tree.Get(item.path)
+		if err != nil {
+			t.Errorf("get %s fail: %s", item.path, err)
+			continue
+		}
+		if string(result) != item.expected {
+			t.Errorf("get %s expected %s, got %s", item.path, item.expected, result)
+		}
+	}
+
+	for path, expected := range map[string]bool{
+		"/users/42":         true,
+		"/users/42/profile": true,
+		"/users/42/other":   false,
+		"/assets/a/b/c":     true,
+		"/missing":          false,
+	} {
+		if got := tree.Has(path); got != expected {
+			t.Errorf("has %s expected %t, got %t", path, expected, got)
+		}
+	}
+
+	if err := tree.Set(NewDirective("/users/:name")); err == nil {
+		t.Errorf("expected conflicting param segment to fail")
+	}
+	if err := tree.Set(NewDirective("/assets/*file/x")); err == nil {
+		t.Errorf("expected non-terminal wildcard segment to fail")
+	}
+}
+
+func TestScopedTree_NoLeak(t *testing.T) {
+	var realizeCount int32
+
+	userProc := &driver.RawProcessor{
+		Proc: func(rc *driver.RealizeContext, before []byte) ([]byte, error) {
+			atomic.AddInt32(&realizeCount, 1)
+			return fmt.Appendf(nil, `{"user":%q}`, rc.Params["user"]), nil
+		},
+	}
+	pathProc := &driver.RawProcessor{
+		Proc: func(rc *driver.RealizeContext, before []byte) ([]byte, error) {
+			return append(before[:len(before)-1:len(before)-1], fmt.Sprintf(`,"path":%q}`, rc.TreePath)...), nil
+		},
+	}
+
+	scoped, err := NewScopedTree(
+		&struct {
+			driver.Modem
+			driver.PathParser
+			driver.StdRealizer
+			driver.DummyDriver
+		}{Modem: driver.DummyModem, PathParser: driver.SlashPathParser},
+		"scoped_test", `{}`, []string{"user"},
+		NewDirective("/", userProc),
+		NewDirective("/a/b", pathProc),
+	)
+	if err != nil {
+		t.Fatalf("build tree fail: %s", err)
+	}
+
+	for _, user := range []string{"alice", "bob", "alice"} {
+		rc := &driver.RealizeContext{Params: map[string]string{"user": user}}
+		result, err := scoped.GetWithContext(rc, "/a/b")
+		if err != nil {
+			t.Fatalf("get for %s fail: %s", user, err)
+		}
+		if expected := fmt.Sprintf(`{"user":%q,"path":"/a/b"}`, user); string(result) != expected {
+			t.Errorf("expected %s, got %s", expected, result)
+		}
+	}
+	if n := atomic.LoadInt32(&realizeCount); n != 2 {
+		t.Errorf("expected root realized once per user, got %d", n)
+	}
+
+	// shared content must stay untouched
+	if root := scoped.(*tree).get(); string(root) != `{}` {
+		t.Errorf("expected shared content untouched, got %s", root)
+	}
+}
+
+func TestScopedTree_Evict(t *testing.T) {
+	scoped, err := NewScopedJSONTree("scoped_evict_test", `{}`, []string{"user"},
+		NewDirective("/a", &driver.JSONProcessor{T: "create", JSONPath: "v", V: []byte("1")}))
+	if err != nil {
+		t.Fatalf("build tree fail: %s", err)
+	}
+	node := scoped.(*tree)
+	var get = func(user string) {
+		if _, err := scoped.GetWithContext(&driver.RealizeContext{Params: map[string]string{"user": user}}, "/a"); err != nil {
+			t.Fatalf("get for %s fail: %s", user, err)
+		}
+	}
+	var cached = func(user string) bool {
+		node.scopeMu.RLock()
+		defer node.scopeMu.RUnlock()
+		_, ok := node.scopeCache[strconv.Quote(user)]
+		return ok
+	}
+
+	// oldest content is evicted when full
+	for i := 0; i <= scopeCacheSize; i++ {
+		get(fmt.Sprint("user", i))
+	}
+	if n := len(node.scopeCache); n != scopeCacheSize {
+		t.Errorf("expected cache bounded by %d, got %d", scopeCacheSize, n)
+	}
+	if cached("user0") || !cached(fmt.Sprint("user", scopeCacheSize)) {
+		t.Errorf("expected oldest content evicted and newest kept")
+	}
+
+	// expired contents are all evicted when full
+	node.cacheTTL = 20 * time.Millisecond
+	time.Sleep(node.cacheTTL)
+	get("fresh")
+	if n := len(node.scopeCache); n != 1 || !cached("fresh") {
+		t.Errorf("expected expired contents evicted, got %d cached", n)
+	}
+}
+
+func TestLazyStaleCacheTree_Revalidate(t *testing.T) {
+	var realizeCount int32
+	release := make(chan struct{})
+
+	slowProcessor := &driver.RawProcessor{
+		Proc: func(_ *driver.RealizeContext, before []byte) ([]byte, error) {
+			n := atomic.AddInt32(&realizeCount, 1)
+			if n > 1 {
+				<-release
+			}
+			return fmt.Appendf(nil, `{"realize":%d}`, n), nil
+		},
+	}
+
+	tree, err := NewLazyStaleCacheTree(
+		&struct {
+			driver.Modem
+			driver.PathParser
+			driver.StdRealizer
+			driver.DummyDriver
+		}{Modem: driver.DummyModem, PathParser: driver.SlashPathParser},
+		"stale_test", `{}`, 20*time.Millisecond, time.Hour,
+		NewDirective("/a", slowProcessor),
+	)
+	if err != nil {
+		t.Fatalf("build tree fail: %s", err)
+	}
+
+	res, err := tree.GetResult(nil, "/a")
+	if err != nil {
+		t.Fatalf("first get fail: %s", err)
+	}
+	if res.Stale || string(res.Content) != `{"realize":1}` {
+		t.Fatalf("expected fresh realize 1, got %+v", res)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	// expired: served stale at once while revalidation blocks in background
+	for i := 0; i < 3; i++ {
+		res, err = tree.GetResult(nil, "/a")
+		if err != nil {
+			t.Fatalf("stale get fail: %s", err)
+		}
+		if !res.Stale || string(res.Content) != `{"realize":1}` {
+			t.Errorf("expected stale realize 1, got stale=%t content=%s", res.Stale, res.Content)
+		}
+	}
+	for deadline := time.Now().Add(time.Second); atomic.LoadInt32(&realizeCount) < 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&realizeCount); n != 2 {
+		t.Errorf("expected one background revalidation, got %d realizations", n)
+	}
+
+	close(release)
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if res, err = tree.GetResult(nil, "/a"); err != nil || !res.Stale {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("revalidated get fail: %s", err)
+	}
+	if res.Stale || string(res.Content) != `{"realize":2}` {
+		t.Errorf("expected fresh realize 2, got stale=%t content=%s", res.Stale, res.Content)
+	}
+}
+
+func TestLazyStaleCacheTree_MaxStale(t *testing.T) {
+	var realizeCount int32
+
+	tree, err := NewLazyStaleCacheTree(
+		&struct {
+			driver.Modem
+			driver.PathParser
+			driver.StdRealizer
+			driver.DummyDriver
+		}{Modem: driver.DummyModem, PathParser: driver.SlashPathParser},
+		"max_stale_test", `{}`, 10*time.Millisecond, 10*time.Millisecond,
+		NewDirective("/", &driver.RawProcessor{
+			Proc: func(_ *driver.RealizeContext, _ []byte) ([]byte, error) {
+				return fmt.Appendf(nil, `{"realize":%d}`, atomic.AddInt32(&realizeCount, 1)), nil
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("build tree fail: %s", err)
+	}
+
+	if _, err := tree.Get("/"); err != nil {
+		t.Fatalf("first get fail: %s", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// beyond ttl+maxStale: realized synchronously
+	res, err := tree.GetResult(nil, "/")
+	if err != nil {
+		t.Fatalf("get fail: %s", err)
+	}
+	if res.Stale || string(res.Content) != `{"realize":2}` {
+		t.Errorf("expected synchronous realize 2, got stale=%t content=%s", res.Stale, res.Content)
+	}
+}
+
+func TestTree_LastGood(t *testing.T) {
+	var failing atomic.Bool
+	var realizeCount int32
+
+	tree, err := NewLazyInstantTree(
+		&struct {
+			driver.Modem
+			driver.PathParser
+			driver.StdRealizer
+			driver.DummyDriver
+		}{Modem: driver.DummyModem, PathParser: driver.SlashPathParser},
+		"last_good_test", `{}`,
+		NewDirective("/a", &driver.RawProcessor{
+			Proc: func(_ *driver.RealizeContext, _ []byte) ([]byte, error) {
+				n := atomic.AddInt32(&realizeCount, 1)
+				if failing.Load() {
+					return nil, fmt.Errorf("upstream down")
+				}
+				return fmt.Appendf(nil, `{"realize":%d}`, n), nil
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("build tree fail: %s", err)
+	}
+
+	// without policy, failure fails the call
+	if _, err := tree.Get("/a"); err != nil {
+		t.Fatalf("first get fail: %s", err)
+	}
+	failing.Store(true)
+	if _, err := tree.Get("/a"); err == nil {
+		t.Fatalf("expected get to fail without last good policy")
+	}
+
+	tree.SetLastGoodPolicy(&LastGoodPolicy{MinBackoff: 30 * time.Millisecond})
+	failing.Store(false)
+	if _, err := tree.Get("/a"); err != nil {
+		t.Fatalf("recovered get fail: %s", err)
+	}
+	good := atomic.LoadInt32(&realizeCount)
+
+	failing.Store(true)
+	for i := 0; i < 3; i++ {
+		res, err := tree.GetResult(nil, "/a")
+		if err != nil {
+			t.Fatalf("degraded get fail: %s", err)
+		}
+		if !res.Degraded || res.Err == nil {
+			t.Errorf("expected degraded result with error, got %+v", res)
+		}
+		if expected := fmt.Sprintf(`{"realize":%d}`, good); string(res.Content) != expected {
+			t.Errorf("expected last good content %s, got %s", expected, res.Content)
+		}
+	}
+	if n := atomic.LoadInt32(&realizeCount); n != good+1 {
+		t.Errorf("expected no retry within backoff, got %d realizations after last good", n-good)
+	}
+
+	failing.Store(false)
+	time.Sleep(40 * time.Millisecond)
+	res, err := tree.GetResult(nil, "/a")
+	if err != nil {
+		t.Fatalf("retried get fail: %s", err)
+	}
+	if res.Degraded {
+		t.Errorf("expected recovered result after backoff, got %+v", res)
+	}
+}
+
+func TestTree_AppendPropagate(t *testing.T) {
+	var directives = []Directive{
+		NewDirective("/", &driver.JSONProcessor{T: "create", JSONPath: "name", V: []byte("root")}),
+		NewDirective("/a", &driver.JSONProcessor{T: "create", JSONPath: "level", V: []byte("a")}),
+		NewDirective("/a/b/c", &driver.JSONProcessor{T: "create", JSONPath: "leaf", V: []byte("c")}),
+	}
+	var updates = []Directive{
+		NewDirective("/", &driver.JSONProcessor{T: "create", JSONPath: "version", V: []byte("2")}),
+		NewDirective("/a", &driver.JSONProcessor{T: "delete", JSONPath: "name"}),
+	}
+
+	for _, build := range []func(...Directive) (Tree, error){
+		func(d ...Directive) (Tree, error) { return NewJSONTree("propagate_test", `{}`, d...) },
+		func(d ...Directive) (Tree, error) { return NewLazyJSONTree("propagate_test", `{}`, d...) },
+	} {
+		tree, err := build(directives...)
+		if err != nil {
+			t.Fatalf("build tree fail: %s", err)
+		}
+		// realize all nodes before update
+		for _, path := range []string{"/a/b/c", "/a/b"} {
+			if _, err := tree.Get(path); err != nil {
+				t.Fatalf("get %s fail: %s", path, err)
+			}
+		}
+		for _, d := range updates {
+			if err := tree.Append(d); err != nil {
+				t.Fatalf("append %s fail: %s", d.Path(), err)
+			}
+		}
+
+		fresh, err := build(append(append([]Directive{}, directives...), updates...)...)
+		if err != nil {
+			t.Fatalf("build fresh tree fail: %s", err)
+		}
+		for _, path := range []string{"/", "/a", "/a/b", "/a/b/c"} {
+			got, _ := tree.Get(path)
+			expected, _ := fresh.Get(path)
+			if string(got) != string(expected) {
+				t.Errorf("get %s after update expected %s, got %s", path, expected, got)
+			}
+		}
+		if got, _ := tree.Get("/a/b/c"); !strings.Contains(string(got), `"version":"2"`) {
+			t.Errorf("expected update propagated to /a/b/c, got %s", got)
+		}
+	}
+}
+
+func TestTree_DirectiveOps(t *testing.T) {
+	var directives = []Directive{
+		NewDirective("/", &driver.JSONProcessor{T: "create", JSONPath: "name", V: []byte("root")}),
+		NewDirective("/a", &driver.JSONProcessor{T: "create", JSONPath: "level", V: []byte("a")}),
+	}
+	var level = func(v string) Directive {
+		return NewDirective("/a", &driver.JSONProcessor{T: "set", JSONPath: "level", V: []byte(`"` + v + `"`)})
+	}
+
+	for _, build := range []func(...Directive) (Tree, error){
+		func(d ...Directive) (Tree, error) { return NewJSONTree("ops_test", `{}`, d...) },
+		func(d ...Directive) (Tree, error) { return NewLazyJSONTree("ops_test", `{}`, d...) },
+	} {
+		tree, err := build(directives...)
+		if err != nil {
+			t.Fatalf("build tree fail: %s", err)
+		}
+		_, _ = tree.Get("/a")
+
+		for _, step := range []struct {
+			op       func() error
+			expected string
+		}{
+			{func() error { return tree.Set(level("b")) }, `{"name":"root","level":"b"}`},
+			{func() error { return tree.Set(level("b")) }, `{"name":"root","level":"b"}`},
+			{func() error { return tree.Append(level("c")) }, `{"name":"root","level":"c"}`},
+			{func() error {
+				return tree.Append(NewDirective("/a", &driver.JSONProcessor{T: "create", JSONPath: "x", V: []byte("1")}))
+			}, `{"name":"root","level":"c","x":"1"}`},
+			{func() error { return tree.Remove("/a") }, `{"name":"root"}`},
+		} {
+			if err := step.op(); err != nil {
+				t.Fatalf("apply directive op fail: %s", err)
+			}
+			if got, _ := tree.Get("/a"); string(got) != step.expected {
+				t.Errorf("expected %s, got %s", step.expected, got)
+			}
+		}
+
+		if !tree.Has("/a") {
+			t.Errorf("expected node /a kept after remove")
+		}
+		if err := tree.Remove("/a/none"); !errors.Is(err, ErrNotExistsNode) {
+			t.Errorf("expected ErrNotExistsNode removing missing node, got %v", err)
+		}
+	}
+}
+
+func TestForest_History(t *testing.T) {
+	var name = func(v string) Directive {
+		return NewDirective("/", &driver.JSONProcessor{T: "create", JSONPath: "name", V: []byte(v)})
+	}
+	f := NewForest(func() Tree {
+		tree, _ := NewJSONTree("history_test", `{}`, name("v1"))
+		return tree
+	})
+	if v := f.Version("history_test"); v != 1 {
+		t.Fatalf("expected version 1 after build, got %d", v)
+	}
+
+	if err := f.Get("history_test").Set(name("v2")); err != nil {
+		t.Fatalf("set fail: %s", err)
+	}
+	if v := f.Version("history_test"); v != 2 {
+		t.Fatalf("expected version 2 after set, got %d", v)
+	}
+	if got, _ := f.GetAt("history_test", 1, "/"); string(got) != `{"name":"v1"}` {
+		t.Errorf("expected v1 content at version 1, got %s", got)
+	}
+	if got, _ := f.GetVal("history_test", "/"); string(got) != `{"name":"v2"}` {
+		t.Errorf("expected v2 content, got %s", got)
+	}
+
+	if err := f.Rollback("history_test", 1); err != nil {
+		t.Fatalf("rollback fail: %s", err)
+	}
+	if v := f.Version("history_test"); v != 3 {
+		t.Errorf("expected version 3 after rollback, got %d", v)
+	}
+	if got, _ := f.GetVal("history_test", "/"); string(got) != `{"name":"v1"}` {
+		t.Errorf("expected v1 content after rollback, got %s", got)
+	}
+
+	f.SetHistoryLimit(2)
+	f.RefreshTree("history_test")
+	if versions := f.Versions("history_test"); fmt.Sprint(versions) != "[3 4]" {
+		t.Errorf("expected versions [3 4] kept, got %v", versions)
+	}
+	if _, err := f.GetAt("history_test", 1, "/"); !errors.Is(err, ErrNotExistsVersion) {
+		t.Errorf("expected ErrNotExistsVersion, got %v", err)
+	}
+
+	// versions go on without snapshots when history disabled
+	f.SetHistoryLimit(0)
+	if err := f.Get("history_test").Set(name("v3")); err != nil {
+		t.Fatalf("set fail: %s", err)
+	}
+	if v, versions := f.Version("history_test"), f.Versions("history_test"); v != 5 || len(versions) != 0 {
+		t.Errorf("expected version 5 without snapshots, got %d %v", v, versions)
+	}
+}
+
+func TestForest_GetAt_lazy(t *testing.T) {
+	var calls int32
+	var counter = func() Directive {
+		return NewDirective("/a", &driver.RawProcessor{Proc: func(_ *driver.RealizeContext, _ []byte) ([]byte, error) {
+			return []byte(fmt.Sprintf(`{"n":%d}`, atomic.AddInt32(&calls, 1))), nil
+		}})
+	}
+	f := NewForest(func() Tree {
+		tree, _ := NewLazyJSONTree("lazy_history_test", `{}`, counter())
+		return tree
+	})
+	tree := f.Get("lazy_history_test")
+
+	if _, err := f.GetAt("lazy_history_test", 1, "/a"); !errors.Is(err, ErrNotRealized) {
+		t.Errorf("expected ErrNotRealized before realization, got %v", err)
+	}
+
+	_, _ = tree.Get("/a")
+	_ = tree.Set(NewDirective("/c")) // version 2 keeps /a realized
+	_ = tree.Set(counter())          // version 3 invalidates /a
+	if got, _ := tree.Get("/a"); string(got) != `{"n":2}` {
+		t.Errorf("expected /a realized again, got %s", got)
+	}
+
+	if got, err := f.GetAt("lazy_history_test", 2, "/a"); err != nil || string(got) != `{"n":1}` {
+		t.Errorf("expected stored content at version 2, got %s, %v", got, err)
+	}
+	if _, err := f.GetAt("lazy_history_test", 3, "/a"); !errors.Is(err, ErrNotRealized) {
+		t.Errorf("expected ErrNotRealized at version 3, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected processor called 2 times, got %d", n)
+	}
+}
+
+func TestDiffTrees(t *testing.T) {
+	old, err := NewJSONTree("diff_test", `{}`,
+		NewDirective("/a", &driver.JSONProcessor{T: "create", JSONPath: "v", V: []byte("1")}),
+		NewDirective("/a/b"),
+		NewDirective("/c"),
+	)
+	if err != nil {
+		t.Fatalf("build tree fail: %s", err)
+	}
+	updated, err := NewJSONTree("diff_test", `{}`,
+		NewDirective("/a", &driver.JSONProcessor{T: "create", JSONPath: "v", V: []byte("2")}),
+		NewDirective("/a/b"),
+		NewDirective("/d"),
+	)
+	if err != nil {
+		t.Fatalf("build tree fail: %s", err)
+	}
+
+	diff, err := DiffTrees(old, updated)
+	if err != nil {
+		t.Fatalf("diff trees fail: %s", err)
+	}
+	var expected = `changed /a: [{"op":"replace","path":"/v","value":"2"}]
+changed /a/b: [{"op":"replace","path":"/v","value":"2"}]
+removed /c
+added /d
+`
+	if got := diff.String(); got != expected {
+		t.Errorf("expected diff:\n%s\ngot:\n%s", expected, got)
+	}
+
+	if diff, _ := DiffTrees(updated, updated); !diff.Empty() {
+		t.Errorf("expected no diff on same tree, got %s", diff)
+	}
+	if diff, _ := DiffTrees(nil, updated); len(diff.Nodes) != 4 || diff.Nodes[0].Kind != NodeAdded {
+		t.Errorf("expected all nodes added, got %s", diff)
+	}
+}
+
+func TestDiffTrees_lazy(t *testing.T) {
+	driver.RegisterRawFunc("test.diff", func(_ *driver.RealizeContext, before []byte) ([]byte, error) { return before, nil })
+	var build = func(value string) Tree {
+		raw, err := driver.NamedRawProcessor("test.diff")
+		if err != nil {
+			t.Fatalf("create raw processor fail: %s", err)
+		}
+		tree, err := NewLazyJSONTree("diff_lazy_test", `{}`,
+			NewDirective("/a", raw, &driver.JSONProcessor{T: "create", JSONPath: "v", V: []byte(value)}))
+		if err != nil {
+			t.Fatalf("build tree fail: %s", err)
+		}
+		return tree
+	}
+
+	// processors built again the same are not changes, though not realized
+	if diff, err := DiffTrees(build("1"), build("1")); err != nil || !diff.Empty() {
+		t.Errorf("expected no diff on trees built the same, got %s, err %v", diff, err)
+	}
+	if diff, err := DiffTrees(build("1"), build("2")); err != nil || diff.String() != "changed /a\n" {
+		t.Errorf("expected /a changed, got %s, err %v", diff, err)
+	}
+}
+
+func TestForest_Watch(t *testing.T) {
+	f := NewForest(func() Tree {
+		tree, _ := NewJSONTree("watch_test", `{}`,
+			NewDirective("/a", &driver.JSONProcessor{T: "create", JSONPath: "v", V: []byte("1")}),
+			NewDirective("/a/b"),
+			NewDirective("/ab"),
+		)
+		return tree
+	})
+	events, stop := f.Watch("watch_test", "/a")
+
+	if err := f.Get("watch_test").Set(NewDirective("/", &driver.JSONProcessor{T: "create", JSONPath: "w", V: []byte("2")})); err != nil {
+		t.Fatalf("set fail: %s", err)
+	}
+	if err := f.Get("watch_test").Del("/a/b"); err != nil {
+		t.Fatalf("del fail: %s", err)
+	}
+	f.RefreshTree("watch_test")
+	stop()
+
+	var got []string
+	for e := range events {
+		if (e.Kind == NodeAdded) != (e.OldDigest == "") || (e.Kind == NodeRemoved) != (e.NewDigest == "") {
+			t.Errorf("unexpected digests on %+v", e)
+		}
+		got = append(got, fmt.Sprintf("%d %s %s", e.Version, e.Kind, e.Path))
+	}
+	var expected = []string{"2 changed /a", "2 changed /a/b", "3 removed /a/b", "4 changed /a", "4 added /a/b"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected events %v, got %v", expected, got)
+	}
+}
+
+func TestForest_Watch_all(t *testing.T) {
+	var builder = func(name string) TreeBuilder {
+		return func() Tree {
+			tree, _ := NewJSONTree(name, `{}`, NewDirective("/a"))
+			return tree
+		}
+	}
+	f := NewForest(builder("x"))
+	events, stop := f.Watch("", "")
+
+	f.Append(builder("y")) // trees added after watching are watched too
+	if err := f.Get("x").Set(NewDirective("/a", &driver.JSONProcessor{T: "create", JSONPath: "v", V: []byte("1")})); err != nil {
+		t.Fatalf("set fail: %s", err)
+	}
+	stop()
+
+	var got []string
+	for e := range events {
+		got = append(got, fmt.Sprintf("%s %d %s %s", e.Tree, e.Version, e.Kind, e.Path))
+	}
+	var expected = []string{"y 1 added /", "y 1 added /a", "x 2 changed /a"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected events %v, got %v", expected, got)
+	}
+}
+
+func TestForest_Watch_lazy(t *testing.T) {
+	var calls int32
+	f := NewForest(func() Tree {
+		tree, _ := NewLazyInstantJSONTree("watch_lazy_test", `{}`,
+			NewDirective("/c", &driver.RawProcessor{Proc: func(_ *driver.RealizeContext, before []byte) ([]byte, error) {
+				atomic.AddInt32(&calls, 1)
+				return before, nil
+			}}),
+			NewDirective("/a"),
+		)
+		tree.SetRateLimit(1, 1)
+		return tree
+	})
+	events, stop := f.Watch("watch_lazy_test", "")
+
+	if err := f.Get("watch_lazy_test").Set(NewDirective("/c", &driver.JSONProcessor{T: "set", JSONPath: "v", V: []byte("1")})); err != nil {
+		t.Fatalf("set fail: %s", err)
+	}
+	stop()
+
+	var got []string
+	for e := range events {
+		got = append(got, fmt.Sprintf("%d %s %s %q", e.Version, e.Kind, e.Path, e.NewDigest))
+	}
+	if expected := []string{`2 changed /c ""`}; fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected events %v, got %v", expected, got)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("expected no node realized for events, got %d processor calls", n)
+	}
+}
+
+func TestTree_Apply(t *testing.T) {
+	var create = func(path, key, value string) Directive {
+		return NewDirective(path, &driver.JSONProcessor{T: "create", JSONPath: key, V: []byte(value)})
+	}
+	f := NewForest(func() Tree {
+		tree, _ := NewJSONTree("tx_test", `{}`, create("/a", "v", "1"), create("/b", "v", "1"))
+		return tree
+	})
+	tree := f.Get("tx_test")
+
+	err := tree.Apply(NewTx().
+		Set(create("/a", "v", "2")).
+		Del("/b").
+		Set(NewDirective("/c", &driver.JSONProcessor{T: "unknown"})))
+	if err == nil {
+		t.Fatalf("expected apply fail on unknown processor")
+	}
+	if got, _ := tree.Get("/a"); string(got) != `{"v":"1"}` || !tree.Has("/b") || tree.Has("/c") {
+		t.Errorf("expected nothing applied on failure, got /a %s", got)
+	}
+	if v := f.Version("tx_test"); v != 1 {
+		t.Errorf("expected no version on failure, got %d", v)
+	}
+
+	if err := tree.Apply(NewTx().
+		Set(create("/", "root", "1")).
+		Set(create("/a", "v", "2")).
+		Del("/b").
+		Append(create("/c", "v", "3"))); err != nil {
+		t.Fatalf("apply fail: %s", err)
+	}
+	for path, expected := range map[string]string{
+		"/a": `{"root":"1","v":"2"}`,
+		"/c": `{"root":"1","v":"3"}`,
+	} {
+		if got, _ := tree.Get(path); string(got) != expected {
+			t.Errorf("get %s expected %s, got %s", path, expected, got)
+		}
+	}
+	if tree.Has("/b") {
+		t.Errorf("expected /b deleted")
+	}
+	if v := f.Version("tx_test"); v != 2 {
+		t.Errorf("expected one version for transaction, got %d", v)
+	}
+}
+
+func TestTree_Apply_lazy(t *testing.T) {
+	var calls int32
+	entered, release := make(chan struct{}), make(chan struct{})
+	slowProcessor := &driver.RawProcessor{
+		Proc: func(_ *driver.RealizeContext, before []byte) ([]byte, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(entered)
+				<-release
+			}
+			return before, nil
+		},
+	}
+	tree, err := NewLazyJSONTree("tx_lazy_test", `{}`,
+		NewDirective("/", slowProcessor),
+		NewDirective("/a", &driver.JSONProcessor{T: "create", JSONPath: "v", V: []byte("1")}))
+	if err != nil {
+		t.Fatalf("build tree fail: %s", err)
+	}
+
+	got := make(chan error, 1)
+	go func() {
+		_, err := tree.Get("/a")
+		got <- err
+	}()
+	<-entered
+
+	// neither the transaction nor new readers wait for the realization in flight
+	applied := make(chan error, 1)
+	go func() {
+		applied <- tree.Apply(NewTx().Set(NewDirective("/a", &driver.JSONProcessor{T: "create", JSONPath: "v", V: []byte("2")})))
+	}()
+	select {
+	case err := <-applied:
+		if err != nil {
+			t.Fatalf("apply fail: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("apply blocked by realization in flight")
+	}
+	read := make(chan []byte, 1)
+	go func() {
+		content, _ := tree.Get("/a")
+		read <- content
+	}()
+	select {
+	case content := <-read:
+		if string(content) != `{"v":"2"}` {
+			t.Errorf("expected applied content, got %s", content)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("get blocked by realization in flight")
+	}
+
+	close(release)
+	if err := <-got; err != nil {
+		t.Errorf("get in flight fail: %s", err)
+	}
+	if content, _ := tree.Get("/a"); string(content) != `{"v":"2"}` {
+		t.Errorf("expected applied content kept, got %s", content)
+	}
+}
+
+func TestForest_StartStop(t *testing.T) {
+	var builds int32
+	f := NewForest(func() Tree {
+		atomic.AddInt32(&builds, 1)
+		tree, _ := NewJSONTree[Directive]("schedule_test", `{}`)
+		return tree
+	})
+	f.SetSchedule("schedule_test", Schedule{Interval: 5 * time.Millisecond, Jitter: 5 * time.Millisecond})
+
+	if err := f.Start(context.Background()); err != nil {
+		t.Fatalf("start fail: %s", err)
+	}
+	if err := f.Start(context.Background()); !errors.Is(err, ErrAlreadyStarted) {
+		t.Errorf("expected ErrAlreadyStarted, got %v", err)
+	}
+	for deadline := time.Now().Add(time.Second); atomic.LoadInt32(&builds) < 4; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected tree rebuilt on schedule, got %d builds", atomic.LoadInt32(&builds))
+		}
+	}
+	f.Stop()
+
+	stopped := atomic.LoadInt32(&builds)
+	time.Sleep(30 * time.Millisecond)
+	if n := atomic.LoadInt32(&builds); n != stopped {
+		t.Errorf("expected no build after stop, got %d more", n-stopped)
+	}
+
+	// started again once ctx done from outside
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := f.Start(ctx); err != nil {
+		t.Fatalf("start after stop fail: %s", err)
+	}
+	cancel()
+	var err error
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if err = f.Start(context.Background()); !errors.Is(err, ErrAlreadyStarted) {
+			break
+		}
+	}
+	if err != nil {
+		t.Errorf("expected start after ctx done, got %v", err)
+	}
+	f.Stop()
+}
+
+func TestForest_CoalesceBuild(t *testing.T) {
+	var builds int32
+	var release = make(chan struct{})
+	f := NewForest(func() Tree {
+		if atomic.AddInt32(&builds, 1) > 1 { // first build is in NewForest
+			<-release
+		}
+		tree, _ := NewJSONTree[Directive]("coalesce_test", `{}`)
+		return tree
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.RefreshTree("coalesce_test")
+		}()
+	}
+	for atomic.LoadInt32(&builds) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond) // let the others join
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&builds); n != 2 {
+		t.Errorf("expected concurrent refreshes coalesced into 1 build, got %d", n-1)
+	}
+	if v := f.Version("coalesce_test"); v != 2 {
+		t.Errorf("expected one version for coalesced refreshes, got %d", v)
+	}
+}
+
+func TestForest_Report(t *testing.T) {
+	var failing atomic.Bool
+	f := NewForest(func() Tree { panic("broken builder") })
+	f.RegisterE("report_test", func() (Tree, error) {
+		if failing.Load() {
+			return nil, fmt.Errorf("bad rule")
+		}
+		return NewJSONTree[Directive]("report_test", `{}`)
+	})
+	f.Build()
+
+	report := f.Report()
+	if len(report.Trees) != 2 || report.OK() {
+		t.Fatalf("expected 2 trees with failure, got %+v", report.Trees)
+	}
+	if failed := report.Failed(); len(failed) != 1 || !strings.HasPrefix(failed[0].Name, "builder#") || failed[0].Failures != 2 {
+		t.Errorf("expected panic reported as failure of unnamed builder twice, got %+v", failed)
+	}
+
+	failing.Store(true)
+	f.RefreshTree("report_test")
+	f.RefreshTree("report_test")
+	for _, tree := range f.Report().Trees {
+		if tree.Name != "report_test" {
+			continue
+		}
+		if tree.Err == nil || tree.Failures != 2 || tree.LastSuccess.IsZero() {
+			t.Errorf("expected 2 consecutive failures after success, got %+v", tree)
+		}
+	}
+	if f.Get("report_test") == nil {
+		t.Errorf("expected tree built last time kept")
+	}
+}
+
+func TestForest_Membership(t *testing.T) {
+	var builds = make(map[string]int)
+	var builder = func(name string) TreeBuilder {
+		return func() Tree {
+			builds[name]++
+			tree, _ := NewJSONTree[Directive](name, `{}`)
+			return tree
+		}
+	}
+	f := NewForest(builder("a"))
+
+	f.Append(builder("b"))
+	if f.Get("b") == nil || builds["a"] != 1 || builds["b"] != 1 {
+		t.Fatalf("expected only appended tree built, got builds %v", builds)
+	}
+
+	f.Register("c", builder("c"))
+	if f.Get("c") != nil {
+		t.Errorf("expected registered tree built on next build")
+	}
+	f.RefreshTree("c")
+	if f.Get("c") == nil {
+		t.Fatalf("expected registered tree built on refresh")
+	}
+
+	f.Register("a", builder("wrong"))
+	f.RefreshTree("a")
+	if report := f.Report(); report.OK() {
+		t.Errorf("expected builder building tree of another name reported")
+	}
+	f.Register("a", builder("a"))
+
+	f.Unregister("b")
+	f.Remove("c")
+	f.Build()
+	if builds["a"] != 2 || builds["b"] != 1 || builds["c"] != 1 {
+		t.Errorf("expected only registered builders run, got builds %v", builds)
+	}
+	if f.Get("b") == nil || f.Get("c") != nil {
+		t.Errorf("expected unregistered tree kept and removed tree dropped, got %v", f.Names())
+	}
+	if err := f.Rollback("c", 1); err != nil || f.Get("c") == nil {
+		t.Errorf("expected removed tree rolled back, got %v", err)
+	}
+}
+
+func TestForest_RemoveBuilding(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	f := NewForest()
+	f.Register("slow", func() Tree {
+		close(entered)
+		<-release
+		tree, _ := NewJSONTree[Directive]("slow", `{}`)
+		return tree
+	})
+	if err := f.Start(context.Background()); err != nil {
+		t.Fatalf("start fail: %s", err)
+	}
+	defer f.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.RefreshTree("slow")
+	}()
+	<-entered
+	f.Remove("slow")
+	close(release)
+	<-done
+
+	if f.Get("slow") != nil || len(f.Names()) != 0 {
+		t.Errorf("expected removed tree not brought back by build in flight, got %v", f.Names())
+	}
+	if report := f.Report(); len(report.Trees) != 0 {
+		t.Errorf("expected no report of removed tree, got %+v", report.Trees)
+	}
+	if f.(*forest).getBuilder("slow") != nil {
+		t.Errorf("expected builder of removed tree not registered again")
+	}
+}
+
+func TestForest_ParallelBuild(t *testing.T) {
+	var running, peak int32
+	var slow atomic.Bool
+	var builder = func(name string, d time.Duration) TreeBuilder {
+		return func() Tree {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for p := atomic.LoadInt32(&peak); n > p && !atomic.CompareAndSwapInt32(&peak, p, n); p = atomic.LoadInt32(&peak) {
+			}
+			if slow.Load() {
+				time.Sleep(d)
+			}
+			tree, _ := NewJSONTree[Directive](name, `{}`)
+			return tree
+		}
+	}
+	f := NewForest(
+		builder("a", 20*time.Millisecond),
+		builder("b", 20*time.Millisecond),
+		builder("c", 20*time.Millisecond),
+		builder("d", 20*time.Millisecond),
+		builder("e", time.Second),
+	)
+	f.SetBuildOptions(BuildOptions{Concurrency: 2, Timeout: 200 * time.Millisecond})
+	slow.Store(true)
+	atomic.StoreInt32(&peak, 0)
+
+	start := time.Now()
+	f.Build()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected build bounded by timeout, took %s", elapsed)
+	}
+	if p := atomic.LoadInt32(&peak); p > 2 {
+		t.Errorf("expected at most 2 builders running, got %d", p)
+	}
+
+	failed := f.Report().Failed()
+	if len(failed) != 1 || failed[0].Name != "e" || !errors.Is(failed[0].Err, context.DeadlineExceeded) {
+		t.Errorf("expected only e timeout, got %+v", failed)
+	}
+	if v := f.Version("e"); v != 1 {
+		t.Errorf("expected e kept its last version, got %d", v)
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if v := f.Version(name); v != 2 {
+			t.Errorf("expected %s rebuilt, got version %d", name, v)
+		}
+	}
+}
+
+func TestForest_BuildTimeout(t *testing.T) {
+	var builds int32
+	f := NewForest()
+	f.SetBuildOptions(BuildOptions{Timeout: 20 * time.Millisecond})
+	f.Register("slow", func() Tree {
+		atomic.AddInt32(&builds, 1)
+		time.Sleep(60 * time.Millisecond)
+		tree, _ := NewJSONTree[Directive]("slow", `{}`)
+		return tree
+	})
+
+	f.Build()
+	if failed := f.Report().Failed(); len(failed) != 1 || !errors.Is(failed[0].Err, context.DeadlineExceeded) {
+		t.Errorf("expected slow build timeout, got %+v", failed)
+	}
+	if f.Get("slow") != nil {
+		t.Errorf("expected tree not published before build done")
+	}
+
+	f.RefreshTree("slow") // joins the build abandoned by Build
+	if f.Get("slow") == nil {
+		t.Errorf("expected abandoned build published when done")
+	}
+	if report := f.Report(); !report.OK() {
+		t.Errorf("expected build reported ok when done, got %+v", report.Failed())
+	}
+	if n := atomic.LoadInt32(&builds); n != 1 {
+		t.Errorf("expected running build joined, got %d builds", n)
+	}
+}
+
+// BenchmarkTree_Get compares lock-free reads by views with reads taking node locks on each level.
+func TestForest_BuildTimeout_stale(t *testing.T) {
+	var slow = func(release chan struct{}) TreeBuilder {
+		return func() Tree {
+			<-release
+			tree, _ := NewJSONTree[Directive]("t", `{"v":"old"}`)
+			return tree
+		}
+	}
+	// running returns done of the build running, closed after its result published
+	var running = func(f Forest) <-chan struct{} {
+		b := f.(*forest).getBuilder("t")
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.call.done
+	}
+
+	// build abandoned by timeout does not bring back the tree removed meanwhile
+	f := NewForest()
+	f.SetBuildOptions(BuildOptions{Timeout: 10 * time.Millisecond})
+	release := make(chan struct{})
+	f.Register("t", slow(release))
+	f.Build()
+	done := running(f)
+	f.Remove("t")
+	close(release)
+	<-done
+	if f.Get("t") != nil || len(f.Names()) != 0 {
+		t.Errorf("expected removed tree not published by abandoned build, got %v", f.Names())
+	}
+
+	// nor overwrites the tree published by a newer build
+	f = NewForest()
+	f.SetBuildOptions(BuildOptions{Timeout: 10 * time.Millisecond})
+	release = make(chan struct{})
+	f.Register("t", slow(release))
+	f.Build()
+	done = running(f)
+	f.Register("t", func() Tree {
+		tree, _ := NewJSONTree[Directive]("t", `{"v":"new"}`)
+		return tree
+	})
+	f.RefreshTree("t")
+	close(release)
+	<-done
+	if got, _ := f.GetVal("t", "/"); string(got) != `{"v":"new"}` {
+		t.Errorf("expected newer build kept, got %s", got)
+	}
+	if report := f.Report(); !report.OK() {
+		t.Errorf("expected newer build reported, got %+v", report.Failed())
+	}
+}
+
+func BenchmarkTree_Get(b *testing.B) {
+	var deep, wide []Directive
+	var deepPath string
+	for i := 0; i < 32; i++ {
+		deepPath += fmt.Sprintf("/n%d", i)
+		deep = append(deep, NewDirective(deepPath, &driver.JSONProcessor{T: "create", JSONPath: fmt.Sprintf("n%d", i), V: []byte("v")}))
+	}
+	for i := 0; i < 1000; i++ {
+		wide = append(wide, NewDirective(fmt.Sprintf("/n%d", i), &driver.JSONProcessor{T: "create", JSONPath: "n", V: []byte("v")}))
+	}
+
+	for _, shape := range []struct {
+		name       string
+		directives []Directive
+		path       string
+	}{
+		{"deep", deep, deepPath},
+		{"wide", wide, "/n500"},
+	} {
+		built, err := NewJSONTree("bench", `{}`, shape.directives...)
+		if err != nil {
+			b.Fatalf("build tree fail: %s", err)
+		}
+		node := built.(*tree)
+
+		b.Run(shape.name+"/view", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := node.Get(shape.path); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+		var txMu sync.RWMutex
+		b.Run(shape.name+"/locked", func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := lockedGet(&txMu, node, shape.path); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// lockedGet read like GetResult did before views: the tree is read locked by txMu,
+// every node on path is checked fresh under realizeMu, realized if not, and its child
+// is matched under mu.
+func lockedGet(txMu *sync.RWMutex, t *tree, path string) ([]byte, error) {
+	txMu.RLock()
+	defer txMu.RUnlock()
+	for {
+		t.realizeMu.RLock()
+		fresh := t.isFresh(t.gen)
+		t.realizeMu.RUnlock()
+		if !fresh {
+			var res Result
+			if _, err := t.realizeWithContext(nil, t.loadView(), &res); err != nil {
+				return nil, err
+			}
+		}
+
+		if t.driver.GetLevel(path) == t.level {
+			return t.get(), nil
+		}
+		child, _, _ := t.matchChild(t.driver.GetNameByLevel(path, t.level+1))
+		next, ok := child.(*tree)
+		if !ok {
+			return t.doFallback(nil, t.get())
+		}
+		t = next
+	}
+}
@@ -7,6 +7,16 @@ import (
 var (
 	// ErrNotExistsTree tree not exists
 	ErrNotExistsTree = errors.New("tree not exists")
+	// ErrNotExistsNode node not exists
+	ErrNotExistsNode = errors.New("node not exists")
+	// ErrNotExistsVersion tree version not exists in history
+	ErrNotExistsVersion = errors.New("version not exists")
+	// ErrNotRealized node content was not realized when the version was recorded
+	ErrNotRealized = errors.New("node not realized")
+	// ErrNilTree tree builder returns no tree
+	ErrNilTree = errors.New("builder returns nil tree")
+	// ErrAlreadyStarted forest already started
+	ErrAlreadyStarted = errors.New("already started")
 	// ErrRateLimited rate limited
 	ErrRateLimited = errors.New("rate limited")
 )
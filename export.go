@@ -1,6 +1,7 @@
 package ivy
 
 import (
+	"context"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -10,19 +11,65 @@ import (
 
 // Forest manages a collection of trees.
 type Forest interface {
-	Register(...TreeBuilder)
+	// Register registers builder of the named tree, replacing the builder registered by name.
+	// The tree is built on next Build or RefreshTree.
+	Register(name string, builder TreeBuilder)
+	// RegisterE registers builder reporting build errors, see Report.
+	RegisterE(name string, builder TreeBuilderE)
+	// Unregister unregisters builder of the named tree, the tree is kept.
+	Unregister(name string)
+	// Remove unregisters builder of the named tree and removes the tree.
+	Remove(name string)
+	// Append builds trees by builders and adds them with their builders.
 	Append(...TreeBuilder) Forest
 
+	// Build builds all trees in parallel, see SetBuildOptions.
 	Build() Forest
+	// SetBuildOptions sets concurrency and timeout of Build.
+	SetBuildOptions(opts BuildOptions)
 	// Refresh refreshes all trees.
 	// interval is optional and only first value is useful when set.
 	// Blocks when the interval is set.
 	Refresh(interval ...time.Duration)
 	// RefreshTree refreshes the specified tree.
 	RefreshTree(name string)
+	// Report returns results of latest builds of all trees,
+	// a failed build keeps the tree built last time.
+	Report() *BuildReport
+
+	// Start rebuilds trees on their schedules in background until ctx done or Stop called.
+	// Concurrent builds of the same tree are coalesced.
+	Start(ctx context.Context) error
+	// Stop stops rebuilding trees, and waits for running builds to finish.
+	Stop()
+	// SetSchedule sets the refresh schedule of the named tree.
+	SetSchedule(name string, s Schedule)
+	// SetDefaultSchedule sets the refresh schedule of trees without their own.
+	SetDefaultSchedule(s Schedule)
 
 	Get(name string) Tree
+	// Names returns names of all trees, sorted.
+	Names() []string
+	// Set sets the tree, and records it as a new version.
+	// Later changes made through the tree are recorded as new versions too.
 	Set(tree Tree)
+
+	// Version returns the current version of the named tree, 0 if not versioned.
+	Version(name string) uint64
+	// Versions returns versions kept in history of the named tree, oldest first.
+	Versions(name string) []uint64
+	// GetAt retrieves a value from the named tree as it was at version.
+	// Stored content is served without running processors, nodes of lazy
+	// trees not realized at that version fail with ErrNotRealized.
+	GetAt(name string, version uint64, path string) (val []byte, err error)
+	// Rollback sets the named tree back to version, recorded as a new version.
+	Rollback(name string, version uint64) error
+	// SetHistoryLimit sets how many versions are kept per tree, n <= 0 disables history.
+	SetHistoryLimit(n int)
+
+	// Watch returns a channel receiving changes on nodes under pathPrefix of the named tree,
+	// or of all trees when treeName is empty, and a function to stop watching.
+	Watch(treeName, pathPrefix string) (<-chan ChangeEvent, func())
 	// GetVal retrieves a value from the named tree at the given path.
 	GetVal(treeName, path string) (val []byte, err error)
 	// GetValWithContext retrieves a value with runtime context.
@@ -45,12 +92,22 @@ type Tree interface {
 	// Returns "" when the tree is the root tree.
 	Path() string
 
-	// Set adds or updates a directive on the tree.
+	// Set replaces the directives on the node at the directive path with it,
+	// the node is created if not exists.
 	Set(Directive) error
+	// Append appends a directive to the directives on the node at the directive path,
+	// the node is created if not exists.
+	Append(Directive) error
+	// Remove removes all directives on the node at the given path, the node is kept.
+	Remove(path string) error
+	// Apply applies all operations in tx atomically, nothing is applied if any fails.
+	Apply(tx *Tx) error
 	// Get retrieves the value at the given path.
 	Get(path string) (val []byte, err error)
 	// GetWithContext retrieves a value with runtime context for dynamic construction.
 	GetWithContext(rc *driver.RealizeContext, path string) (val []byte, err error)
+	// GetResult retrieves a value with runtime context, along with metadata about how it was served.
+	GetResult(rc *driver.RealizeContext, path string) (*Result, error)
 
 	// Has checks if a node exists at the given path.
 	Has(path string) bool
@@ -62,6 +119,9 @@ type Tree interface {
 
 	// ShowStruct returns the tree structure as JSON.
 	ShowStruct() []byte
+	// Export dumps template, mode, driver and directives of every node,
+	// which can be rebuilt by ImportTree.
+	Export() ([]byte, error)
 
 	// SetRateLimit sets a rate limit for Get calls on this tree.
 	SetRateLimit(r rate.Limit, burst int)
@@ -73,6 +133,10 @@ type Tree interface {
 	// SetDefaultContext sets the default RealizeContext used by realize when
 	// no request-scoped context is provided (e.g. via Get or during build).
 	SetDefaultContext(rc *driver.RealizeContext)
+
+	// SetLastGoodPolicy sets the policy to keep serving the last good content
+	// when realization fails. nil disables it.
+	SetLastGoodPolicy(policy *LastGoodPolicy)
 }
 
 // Directive defines a path and the processors to apply at that path.
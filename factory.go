@@ -10,11 +10,19 @@ import (
 
 // NewForest builds a new forest and returns it.
 func NewForest(builders ...TreeBuilder) Forest {
-	return (&forest{
+	return newForest(builders...).Build()
+}
+
+func newForest(builders ...TreeBuilder) *forest {
+	f := &forest{
 		m:        make(map[string]Tree, len(builders)),
-		builders: builders,
-		builderM: make(map[string]TreeBuilder, len(builders)),
-	}).Build()
+		builderM: make(map[string]*builder, len(builders)),
+		limit:    DefaultHistoryLimit,
+	}
+	for _, build := range builders {
+		f.addBuilder(newBuilder(build))
+	}
+	return f
 }
 
 // NewJSONTree builds a JSON tree.
@@ -37,6 +45,16 @@ func NewLazyCacheJSONTree[R Directive](name, template string, ttl time.Duration,
 	return NewLazyCacheTree(driver.NewJSONDriver(), name, template, ttl, directives...)
 }
 
+// NewLazyStaleCacheJSONTree builds a lazy JSON tree with cache TTL, serving stale content while revalidating.
+func NewLazyStaleCacheJSONTree[R Directive](name, template string, ttl, maxStale time.Duration, directives ...R) (Tree, error) {
+	return NewLazyStaleCacheTree(driver.NewJSONDriver(), name, template, ttl, maxStale, directives...)
+}
+
+// NewScopedJSONTree builds a scoped JSON tree, results are cached by values of keys in Params.
+func NewScopedJSONTree[R Directive](name, template string, keys []string, directives ...R) (Tree, error) {
+	return NewScopedTree(driver.NewJSONDriver(), name, template, keys, directives...)
+}
+
 // NewYAMLTree builds a YAML tree.
 func NewYAMLTree[R Directive](name, template string, directives ...R) (Tree, error) {
 	return NewTree(driver.NewYAMLDriver(), name, template, directives...)
@@ -57,6 +75,16 @@ func NewLazyCacheYAMLTree[R Directive](name, template string, ttl time.Duration,
 	return NewLazyCacheTree(driver.NewYAMLDriver(), name, template, ttl, directives...)
 }
 
+// NewLazyStaleCacheYAMLTree builds a lazy YAML tree with cache TTL, serving stale content while revalidating.
+func NewLazyStaleCacheYAMLTree[R Directive](name, template string, ttl, maxStale time.Duration, directives ...R) (Tree, error) {
+	return NewLazyStaleCacheTree(driver.NewYAMLDriver(), name, template, ttl, maxStale, directives...)
+}
+
+// NewScopedYAMLTree builds a scoped YAML tree, results are cached by values of keys in Params.
+func NewScopedYAMLTree[R Directive](name, template string, keys []string, directives ...R) (Tree, error) {
+	return NewScopedTree(driver.NewYAMLDriver(), name, template, keys, directives...)
+}
+
 // NewTileTree builds a tile tree.
 func NewTileTree[R Directive](name, template string, directives ...R) (Tree, error) {
 	return NewTree(driver.NewTileDriver(), name, template, directives...)
@@ -77,6 +105,16 @@ func NewLazyCacheTileTree[R Directive](name, template string, ttl time.Duration,
 	return NewLazyCacheTree(driver.NewTileDriver(), name, template, ttl, directives...)
 }
 
+// NewLazyStaleCacheTileTree builds a lazy tile tree with cache TTL, serving stale content while revalidating.
+func NewLazyStaleCacheTileTree[R Directive](name, template string, ttl, maxStale time.Duration, directives ...R) (Tree, error) {
+	return NewLazyStaleCacheTree(driver.NewTileDriver(), name, template, ttl, maxStale, directives...)
+}
+
+// NewScopedTileTree builds a scoped tile tree, results are cached by values of keys in Params.
+func NewScopedTileTree[R Directive](name, template string, keys []string, directives ...R) (Tree, error) {
+	return NewScopedTree(driver.NewTileDriver(), name, template, keys, directives...)
+}
+
 // NewXMLTree builds an XML tree.
 func NewXMLTree[R Directive](name, template string, directives ...R) (Tree, error) {
 	return NewTree(driver.NewXMLDriver(), name, template, directives...)
@@ -97,6 +135,16 @@ func NewLazyCacheXMLTree[R Directive](name, template string, ttl time.Duration,
 	return NewLazyCacheTree(driver.NewXMLDriver(), name, template, ttl, directives...)
 }
 
+// NewLazyStaleCacheXMLTree builds a lazy XML tree with cache TTL, serving stale content while revalidating.
+func NewLazyStaleCacheXMLTree[R Directive](name, template string, ttl, maxStale time.Duration, directives ...R) (Tree, error) {
+	return NewLazyStaleCacheTree(driver.NewXMLDriver(), name, template, ttl, maxStale, directives...)
+}
+
+// NewScopedXMLTree builds a scoped XML tree, results are cached by values of keys in Params.
+func NewScopedXMLTree[R Directive](name, template string, keys []string, directives ...R) (Tree, error) {
+	return NewScopedTree(driver.NewXMLDriver(), name, template, keys, directives...)
+}
+
 // NewTOMLTree builds a TOML tree.
 func NewTOMLTree[R Directive](name, template string, directives ...R) (Tree, error) {
 	return NewTree(driver.NewTOMLDriver(), name, template, directives...)
@@ -117,6 +165,16 @@ func NewLazyCacheTOMLTree[R Directive](name, template string, ttl time.Duration,
 	return NewLazyCacheTree(driver.NewTOMLDriver(), name, template, ttl, directives...)
 }
 
+// NewLazyStaleCacheTOMLTree builds a lazy TOML tree with cache TTL, serving stale content while revalidating.
+func NewLazyStaleCacheTOMLTree[R Directive](name, template string, ttl, maxStale time.Duration, directives ...R) (Tree, error) {
+	return NewLazyStaleCacheTree(driver.NewTOMLDriver(), name, template, ttl, maxStale, directives...)
+}
+
+// NewScopedTOMLTree builds a scoped TOML tree, results are cached by values of keys in Params.
+func NewScopedTOMLTree[R Directive](name, template string, keys []string, directives ...R) (Tree, error) {
+	return NewScopedTree(driver.NewTOMLDriver(), name, template, keys, directives...)
+}
+
 // NewTree builds a standard tree.
 func NewTree[R Directive](driver driver.Driver, name, template string, directives ...R) (Tree, error) {
 	return buildTree(newTree[R](driver, name, template), toA(directives...)...)
@@ -138,16 +196,36 @@ func NewLazyCacheTree[R Directive](driver driver.Driver, name, template string,
 	return buildTree(newTree[R](driver, name, template).lazy().cache(ttl), toA(directives...)...)
 }
 
+// NewLazyStaleCacheTree builds a lazy tree with cache TTL.
+// After the TTL expires, the next Get() returns the stale content at once and triggers
+// re-realization in background. Content older than ttl+maxStale is realized synchronously,
+// zero maxStale means stale content is always served.
+func NewLazyStaleCacheTree[R Directive](driver driver.Driver, name, template string, ttl, maxStale time.Duration, directives ...R) (Tree, error) {
+	return buildTree(newTree[R](driver, name, template).lazy().cache(ttl).stale(maxStale), toA(directives...)...)
+}
+
+// NewScopedTree builds a scoped tree.
+// Every request realizes content on its own, so Params never leak between requests.
+// When keys are set, results are cached by the values of these keys in Params.
+func NewScopedTree[R Directive](driver driver.Driver, name, template string, keys []string, directives ...R) (Tree, error) {
+	return buildTree(newTree[R](driver, name, template).lazy().scoped(keys...), toA(directives...)...)
+}
+
 func newTree[R Directive](diver driver.Driver, name, template string) *tree {
-	return &tree{
+	t := &tree{
 		name: name,
 
+		template: []byte(template),
+
 		defaultCtx: &driver.RealizeContext{Context: context.Background()},
 
+		base:     []byte(template),
 		content:  []byte(template),
 		driver:   diver,
 		children: make(map[string]Tree),
 	}
+	t.refresh()
+	return t
 }
 func buildTree(tree *tree, directives ...Directive) (Tree, error) {
 	if err := tree.build(directives...); err != nil {
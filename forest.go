@@ -2,11 +2,10 @@ package ivy
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/tr1v3r/pkg/guard"
-	"github.com/tr1v3r/pkg/log"
 	"golang.org/x/time/rate"
 
 	"github.com/tr1v3r/ivy/driver"
@@ -23,24 +22,103 @@ type forest struct {
 	m  map[string]Tree
 
 	bMu      sync.RWMutex
-	builders []TreeBuilder
-	builderM map[string]TreeBuilder
+	builders []*builder
+	builderM map[string]*builder
+	opts     BuildOptions
+	// published seq of the latest build result published by tree name
+	published map[string]uint64
 
 	rlMu        sync.RWMutex
 	rateLimiter *rate.Limiter
+
+	// histories versioned snapshots of trees by name, limit bounds snapshots kept per tree,
+	// trees are not snapshot unless watched when limit is not positive
+	hMu       sync.RWMutex
+	histories map[string]*history
+	limit     int
+
+	// watchers receive changes on trees
+	wMu      sync.Mutex
+	watchers []*watcher
+	// notifications pending for watchers, delivered in version order by one goroutine
+	nMu       sync.Mutex
+	pending   []notification
+	notifying bool
+
+	// scheduler refreshes trees on schedules after Start
+	scheduler
+
+	// reports latest build results by tree name
+	repMu   sync.RWMutex
+	reports map[string]*TreeReport
 }
 
-// Register register tree builder
-func (f *forest) Register(builders ...TreeBuilder) { f.appendBuilders(builders...) }
+// Register registers builder of the named tree, replacing the builder registered by name.
+// The tree is built on next Build or RefreshTree, and must be named name.
+func (f *forest) Register(name string, build TreeBuilder) {
+	f.bind(name, newBuilder(build).named(name))
+}
+
+// RegisterE registers builder reporting error of the named tree, like Register.
+func (f *forest) RegisterE(name string, build TreeBuilderE) {
+	f.bind(name, newBuilderE(build).named(name))
+}
 
-// BindTreeBuilder bind tree and builder
-func (f *forest) BindTreeBuilder(name string, builder TreeBuilder) {
+// Unregister unregisters builder of the named tree, the tree is kept but never rebuilt.
+func (f *forest) Unregister(name string) {
 	f.bMu.Lock()
-	defer f.bMu.Unlock()
-	f.builderM[name] = builder
+	if b := f.builderM[name]; b != nil {
+		delete(f.builderM, name)
+		f.builders = removeBuilder(f.builders, b)
+	}
+	delete(f.published, name)
+	f.bMu.Unlock()
+
+	f.unschedule(name)
+}
+
+// Remove unregisters builder of the named tree and removes the tree.
+// The removal is recorded as a new version, history is kept so the tree can be rolled back.
+func (f *forest) Remove(name string) {
+	f.Unregister(name)
+
+	f.mu.Lock()
+	delete(f.m, name)
+	f.mu.Unlock()
+
+	f.repMu.Lock()
+	delete(f.reports, name)
+	f.repMu.Unlock()
+
+	f.drop(name)
+}
+
+// bind builder to tree name, the builder bound before is replaced
+func (f *forest) bind(name string, b *builder) {
+	f.bMu.Lock()
+	f.bindLocked(name, b)
+	f.bMu.Unlock()
+
+	f.schedule(name)
+}
+
+// bindLocked bind builder to tree name, must be called with bMu held
+func (f *forest) bindLocked(name string, b *builder) {
+	if f.builderM == nil {
+		f.builderM = make(map[string]*builder)
+	}
+	switch old := f.builderM[name]; {
+	case old == b:
+	case old != nil:
+		f.builders = replaceBuilder(f.builders, old, b)
+	case !hasBuilder(f.builders, b): // b may be added before bound
+		f.builders = appendBuilder(f.builders, b)
+	}
+	f.builderM[name] = b
 }
 
 // Refresh refresh rule forest
+// Deprecated: Refresh with interval blocks forever, use Start and Stop instead.
 func (f *forest) Refresh(interval ...time.Duration) {
 	if len(interval) == 0 {
 		f.Build()
@@ -52,32 +130,64 @@ func (f *forest) Refresh(interval ...time.Duration) {
 }
 
 // RefreshTree refresh tree
+// joining a build already running waits for it to be published instead of building again.
 func (f *forest) RefreshTree(name string) {
-	if build := f.getBuilder(name); build != nil {
-		f.Set(build())
+	if b := f.getBuilder(name); b != nil {
+		f.build(b)
 	}
 }
 
 // Build all trees in forest
+// Builders run in parallel bounded by BuildOptions, trees are published in the order
+// of builders. Builds not done in time are reported as timeout, and published when done.
 func (f *forest) Build() Forest {
-	for _, build := range f.getBuilders() {
-		if tree := build(); tree != nil {
-			f.Set(tree)
-			f.BindTreeBuilder(tree.Name(), build)
-		}
-	}
+	f.buildAll(f.getBuilders())
 	return f
 }
 
-// Append append tree and builder to forest
+// Append builds trees by builders and adds them to forest with their builders,
+// other trees are not rebuilt.
 func (f *forest) Append(builders ...TreeBuilder) Forest {
-	for _, builder := range f.getBuilders() {
-		f.Register(builder)
-		f.Set(builder())
+	for _, build := range builders {
+		b := newBuilder(build)
+		f.addBuilder(b)
+		f.build(b)
 	}
 	return f
 }
 
+// build run builder, set and bind the tree built, and report the result.
+// joining a build already running waits for its result published by the running build.
+func (f *forest) build(b *builder) { b.run(func(res buildResult) { f.publish(b, res) }) }
+
+// publish set and bind the tree built, and report the result.
+// results of builders unregistered meanwhile are dropped, so removed trees never come back,
+// and so are results of builds started before the one published last.
+func (f *forest) publish(b *builder, res buildResult) {
+	name := b.key(res.tree)
+
+	// bMu is held until the result is reported, so Unregister and Remove either drop it or remove it
+	f.bMu.Lock()
+	if !hasBuilder(f.builders, b) || res.seq < f.published[name] {
+		f.bMu.Unlock()
+		return
+	}
+	if f.published == nil {
+		f.published = make(map[string]uint64)
+	}
+	f.published[name] = res.seq
+	if res.err == nil {
+		f.Set(res.tree)
+		f.bindLocked(res.tree.Name(), b)
+	}
+	f.report(name, res.at, res.duration, res.err)
+	f.bMu.Unlock()
+
+	if res.err == nil {
+		f.schedule(res.tree.Name())
+	}
+}
+
 // Get get rule tree by name
 func (f *forest) Get(name string) Tree {
 	f.mu.RLock()
@@ -91,12 +201,18 @@ func (f *forest) Set(tree Tree) {
 		return
 	}
 
+	if o, ok := tree.(observable); ok {
+		o.observe(f.record)
+	}
+
 	f.mu.Lock()
-	defer f.mu.Unlock()
 	if f.m == nil {
 		f.m = make(map[string]Tree, 16)
 	}
 	f.m[tree.Name()] = tree
+	f.mu.Unlock()
+
+	f.record(tree)
 }
 
 // GetVal get value from tree
@@ -162,6 +278,13 @@ func (f *forest) count() int {
 	return len(f.m)
 }
 
+// Names returns names of all trees, sorted.
+func (f *forest) Names() []string {
+	names := f.names()
+	sort.Strings(names)
+	return names
+}
+
 func (f *forest) names() (names []string) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
@@ -171,30 +294,58 @@ func (f *forest) names() (names []string) {
 	return
 }
 
-func (f *forest) getBuilders() []TreeBuilder {
+func (f *forest) getBuilders() []*builder {
 	f.bMu.RLock()
 	defer f.bMu.RUnlock()
 	return f.builders
 }
-func (f *forest) appendBuilders(builders ...TreeBuilder) {
-	var wrappedBuilders = make([]TreeBuilder, len(builders))
-	for i, builder := range builders {
-		wrappedBuilders[i] = func() Tree {
-			defer func() {
-				if e := recover(); e != nil {
-					log.Error("build tree panic: %s, stack: %s", e, guard.CatchStack())
-				}
-			}()
-			return builder()
-		}
-	}
 
+// addBuilder add builder not bound to any name yet, bound when tree built
+func (f *forest) addBuilder(b *builder) {
 	f.bMu.Lock()
 	defer f.bMu.Unlock()
-	f.builders = append(f.builders, wrappedBuilders...)
+	f.builders = appendBuilder(f.builders, b)
 }
-func (f *forest) getBuilder(name string) TreeBuilder {
+func (f *forest) getBuilder(name string) *builder {
 	f.bMu.RLock()
 	defer f.bMu.RUnlock()
 	return f.builderM[name]
 }
+
+// appendBuilder return a new slice with b appended, builders is shared with readers
+func appendBuilder(builders []*builder, b *builder) []*builder {
+	return append(builders[:len(builders):len(builders)], b)
+}
+
+// removeBuilder return a new slice without b, builders is shared with readers
+func removeBuilder(builders []*builder, b *builder) (left []*builder) {
+	for _, v := range builders {
+		if v != b {
+			left = append(left, v)
+		}
+	}
+	return left
+}
+
+// replaceBuilder return a new slice with old replaced by b in place, builders is shared with readers
+func replaceBuilder(builders []*builder, old, b *builder) (replaced []*builder) {
+	for _, v := range builders {
+		switch v {
+		case old:
+			replaced = append(replaced, b)
+		case b:
+		default:
+			replaced = append(replaced, v)
+		}
+	}
+	return replaced
+}
+
+func hasBuilder(builders []*builder, b *builder) bool {
+	for _, v := range builders {
+		if v == b {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,283 @@
+package ivy
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/tr1v3r/ivy/driver"
+)
+
+// DefaultHistoryLimit is the number of versions kept per tree by default.
+const DefaultHistoryLimit = 16
+
+// observable is a tree notifying changes made through it
+type observable interface {
+	observe(fn func(Tree))
+}
+
+// snapshot is a version of a tree kept in history
+type snapshot struct {
+	version uint64
+	tree    *tree
+}
+
+// history versions of a tree, oldest first
+type history struct {
+	version   uint64 // latest version ever assigned, monotonic
+	snapshots []snapshot
+}
+
+// find return snapshot of version
+func (h *history) find(version uint64) (*tree, bool) {
+	for _, s := range h.snapshots {
+		if s.version == version {
+			return s.tree, true
+		}
+	}
+	return nil, false
+}
+
+// record take a snapshot of tree as a new version, if tree is still the one in forest.
+// trees not built by this package cannot be cloned and are not versioned.
+// tree is snapshot only when history enabled or watched, otherwise the version is bumped.
+func (f *forest) record(t Tree) {
+	node, ok := t.(*tree)
+	if !ok || f.Get(t.Name()) != t {
+		return
+	}
+	if !f.snapshotting(t.Name()) {
+		f.bump(t.Name())
+		return
+	}
+	f.push(t.Name(), node.clone())
+}
+
+// snapshotting check if changes of the named tree are snapshot, for history or watchers
+func (f *forest) snapshotting(name string) bool {
+	f.hMu.RLock()
+	limit := f.limit
+	f.hMu.RUnlock()
+	if limit > 0 {
+		return true
+	}
+
+	f.wMu.Lock()
+	defer f.wMu.Unlock()
+	for _, w := range f.watchers {
+		if w.tree == "" || w.tree == name {
+			return true
+		}
+	}
+	return false
+}
+
+// bump assign a new version to the named tree without snapshot, snapshots before are dropped
+func (f *forest) bump(name string) {
+	f.hMu.Lock()
+	defer f.hMu.Unlock()
+	h := f.history(name)
+	h.version++
+	h.snapshots = nil
+}
+
+// history get history of the named tree, created if not exists, must be called with hMu held
+func (f *forest) history(name string) *history {
+	if f.histories == nil {
+		f.histories = make(map[string]*history)
+	}
+	h := f.histories[name]
+	if h == nil {
+		h = &history{}
+		f.histories[name] = h
+	}
+	return h
+}
+
+// push append snapshot as a new version of the named tree,
+// and queue the change from previous snapshot for watchers.
+func (f *forest) push(name string, t *tree) {
+	f.hMu.Lock()
+	defer f.hMu.Unlock()
+	h := f.history(name)
+	var prev *tree
+	if n := len(h.snapshots); n > 0 {
+		prev = h.snapshots[n-1].tree
+	}
+
+	h.version++
+	h.snapshots = append(h.snapshots, snapshot{version: h.version, tree: t})
+	// the latest snapshot is kept for watchers to diff on even if history disabled
+	if limit := f.limit; len(h.snapshots) > limit {
+		if limit <= 0 {
+			limit = 1
+		}
+		h.snapshots = append(h.snapshots[:0:0], h.snapshots[len(h.snapshots)-limit:]...)
+	}
+	f.enqueue(notification{name: name, version: h.version, prev: prev, curr: t})
+}
+
+// drop record removal of the named tree as a new version, snapshots are kept for Rollback.
+func (f *forest) drop(name string) {
+	f.hMu.Lock()
+	defer f.hMu.Unlock()
+	h := f.histories[name]
+	if h == nil {
+		return
+	}
+	var prev *tree
+	if n := len(h.snapshots); n > 0 {
+		prev = h.snapshots[n-1].tree
+	}
+	h.version++
+	f.enqueue(notification{name: name, version: h.version, prev: prev})
+}
+
+// Versions returns versions kept in history of the named tree, oldest first.
+func (f *forest) Versions(name string) (versions []uint64) {
+	f.hMu.RLock()
+	defer f.hMu.RUnlock()
+	if h := f.histories[name]; h != nil {
+		for _, s := range h.snapshots {
+			versions = append(versions, s.version)
+		}
+	}
+	return versions
+}
+
+// Version returns the current version of the named tree, 0 if not versioned.
+func (f *forest) Version(name string) uint64 {
+	f.hMu.RLock()
+	defer f.hMu.RUnlock()
+	if h := f.histories[name]; h != nil {
+		return h.version
+	}
+	return 0
+}
+
+// GetAt retrieves a value from the named tree as it was at version.
+// Content stored in the snapshot is served and processors are never run again,
+// so nodes of lazy trees not realized by then fail with ErrNotRealized.
+func (f *forest) GetAt(name string, version uint64, path string) ([]byte, error) {
+	t, err := f.snapshotAt(name, version)
+	if err != nil {
+		return nil, err
+	}
+	return t.stored(nil, path)
+}
+
+// stored get content stored on the node at path from t down without realizing any node.
+// foreign subtrees are not kept in snapshots, they are read as they are now.
+func (t *tree) stored(rc *driver.RealizeContext, path string) ([]byte, error) {
+	content, ok := t.storedContent()
+	if !ok {
+		return nil, fmt.Errorf("get %s: %w", t.Path(), ErrNotRealized)
+	}
+	if t.driver.GetLevel(path) == t.level {
+		return content, nil
+	}
+
+	name := t.driver.GetNameByLevel(path, t.level+1)
+	if child, kind, param := t.matchChild(name); child != nil {
+		rc, path = t.capture(rc, child, kind, param, name, path)
+		if child, ok := child.(*tree); ok {
+			return child.stored(rc, path)
+		}
+		return child.GetWithContext(rc, path)
+	}
+	return t.doFallback(rc, content)
+}
+
+// Rollback sets the named tree back to the snapshot of version.
+// Rollback is recorded as a new version, so history keeps moving forward.
+func (f *forest) Rollback(name string, version uint64) error {
+	t, err := f.snapshotAt(name, version)
+	if err != nil {
+		return err
+	}
+	f.Set(t.clone())
+	return nil
+}
+
+// SetHistoryLimit sets how many versions are kept per tree, DefaultHistoryLimit by default.
+// n <= 0 disables history, trees are not snapshot on changes unless watched.
+func (f *forest) SetHistoryLimit(n int) {
+	f.hMu.Lock()
+	defer f.hMu.Unlock()
+	f.limit = n
+}
+
+// snapshotAt return snapshot tree of version
+func (f *forest) snapshotAt(name string, version uint64) (*tree, error) {
+	f.hMu.RLock()
+	defer f.hMu.RUnlock()
+	h := f.histories[name]
+	if h == nil {
+		return nil, ErrNotExistsTree
+	}
+	t, ok := h.find(version)
+	if !ok {
+		return nil, fmt.Errorf("tree %s version %d: %w", name, version, ErrNotExistsVersion)
+	}
+	return t, nil
+}
+
+// clone deep copy tree and all subtrees with their directives and realized content,
+// the copy shares nothing mutable with t, so it stays the same whatever happens to t.
+// subtrees not built by this package are shared.
+func (t *tree) clone() *tree {
+	t.realizeMu.RLock()
+	directives, procs, realizedAt, lastGood := t.directives, t.procs, t.realizedAt, t.lastGood
+	if t.realizedGen != t.gen { // content is not realized by procs
+		realizedAt = time.Time{}
+	}
+	base, content := t.getBase(), t.get()
+	t.realizeMu.RUnlock()
+
+	c := &tree{
+		name:     t.name,
+		path:     t.path,
+		template: t.template,
+
+		defaultCtx: t.defaultCtx,
+		fallback:   t.fallback,
+		lastGood:   lastGood,
+
+		driver:      t.driver,
+		lazyMode:    t.lazyMode,
+		instantMode: t.instantMode,
+		cacheTTL:    t.cacheTTL,
+		staleMode:   t.staleMode,
+		maxStale:    t.maxStale,
+		scopedMode:  t.scopedMode,
+		scopeKeys:   t.scopeKeys,
+
+		level:      t.level,
+		directives: directives,
+		procs:      procs,
+		realizedAt: realizedAt,
+		base:       base,
+		content:    content,
+		children:   make(map[string]Tree),
+	}
+
+	t.rlMu.RLock()
+	if limiter := t.rateLimiter; limiter != nil {
+		c.rateLimiter = rate.NewLimiter(limiter.Limit(), limiter.Burst())
+	}
+	t.rlMu.RUnlock()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	c.paramChild, c.wildcardChild = t.paramChild, t.wildcardChild
+	for name, child := range t.children {
+		if child, ok := child.(*tree); ok {
+			c.children[name] = child.clone()
+			continue
+		}
+		c.children[name] = child
+	}
+	c.refresh()
+	return c
+}
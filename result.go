@@ -0,0 +1,89 @@
+package ivy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Result is the content got from tree with metadata about how it was served.
+type Result struct {
+	// Content is the realized content of the target node
+	Content []byte
+
+	// Stale is true when any node on the path served expired content
+	// while being revalidated in background
+	Stale bool
+	// Age is the longest time since realization among stale nodes
+	Age time.Duration
+
+	// Degraded is true when any node on the path failed to realize
+	// and served its last good content instead
+	Degraded bool
+	// Err holds the realization errors of degraded nodes
+	Err error
+}
+
+// markStale record a node served stale content realized age ago
+func (r *Result) markStale(age time.Duration) {
+	if r == nil {
+		return
+	}
+	r.Stale = true
+	if age > r.Age {
+		r.Age = age
+	}
+}
+
+// markDegraded record a node served last good content after failing with err
+func (r *Result) markDegraded(path string, err error) {
+	if r == nil {
+		return
+	}
+	r.Degraded = true
+	r.Err = errors.Join(r.Err, fmt.Errorf("degraded on %s: %w", path, err))
+}
+
+// merge merge metadata of result got from a subtree and return its content
+func (r *Result) merge(sub *Result, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	if sub.Stale {
+		r.markStale(sub.Age)
+	}
+	if sub.Degraded {
+		r.Degraded = true
+		r.Err = errors.Join(r.Err, sub.Err)
+	}
+	return sub.Content, nil
+}
+
+// LastGoodPolicy policy to serve last good content when realization fails.
+// Failed realization is retried after MinBackoff, doubled on each
+// consecutive failure up to MaxBackoff.
+type LastGoodPolicy struct {
+	// MinBackoff is the wait before the first retry, default 1s
+	MinBackoff time.Duration
+	// MaxBackoff is the upper bound of wait between retries, default 1m
+	MaxBackoff time.Duration
+}
+
+// backoff return the wait before next retry after failures consecutive failures
+func (p *LastGoodPolicy) backoff(failures int) time.Duration {
+	wait, limit := p.MinBackoff, p.MaxBackoff
+	if wait <= 0 {
+		wait = time.Second
+	}
+	if limit <= 0 {
+		limit = time.Minute
+	}
+
+	for i := 1; i < failures && wait < limit; i++ {
+		wait *= 2
+	}
+	if wait > limit {
+		wait = limit
+	}
+	return wait
+}
@@ -0,0 +1,173 @@
+package ivy
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Schedule is how often a tree is rebuilt after forest started.
+type Schedule struct {
+	// Interval between two builds, zero or negative means never
+	Interval time.Duration
+	// Jitter is the upper bound of random delay added to each interval,
+	// so trees sharing a schedule do not rebuild at the same moment
+	Jitter time.Duration
+}
+
+// next return delay before next build
+func (s Schedule) next() time.Duration {
+	if s.Jitter <= 0 {
+		return s.Interval
+	}
+	return s.Interval + time.Duration(rand.Int63n(int64(s.Jitter)))
+}
+
+// scheduler rebuild trees on their schedules in background
+type scheduler struct {
+	sMu       sync.Mutex
+	schedules map[string]Schedule
+	fallback  Schedule // schedule for trees without their own
+
+	ctx    context.Context // nil when not started
+	cancel context.CancelFunc
+	loops  map[string]context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// SetSchedule sets the refresh schedule of the named tree, takes effect immediately if started.
+func (f *forest) SetSchedule(name string, s Schedule) {
+	f.sMu.Lock()
+	if f.schedules == nil {
+		f.schedules = make(map[string]Schedule)
+	}
+	f.schedules[name] = s
+	f.sMu.Unlock()
+
+	f.restart(name)
+}
+
+// SetDefaultSchedule sets the refresh schedule of trees without their own, takes effect immediately if started.
+func (f *forest) SetDefaultSchedule(s Schedule) {
+	f.sMu.Lock()
+	f.fallback = s
+	f.sMu.Unlock()
+
+	for _, name := range f.builderNames() {
+		f.restart(name)
+	}
+}
+
+// Start rebuilds trees with builders on their schedules in background until ctx done or Stop called.
+// forest can be started again once ctx done.
+func (f *forest) Start(ctx context.Context) error {
+	f.sMu.Lock()
+	if f.ctx != nil {
+		f.sMu.Unlock()
+		return ErrAlreadyStarted
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	f.ctx, f.cancel = ctx, cancel
+	f.loops = make(map[string]context.CancelFunc)
+	f.sMu.Unlock()
+
+	// clear started state when ctx done from outside, Stop clears it by itself
+	go func() {
+		<-ctx.Done()
+		f.sMu.Lock()
+		defer f.sMu.Unlock()
+		if f.ctx == ctx {
+			f.ctx, f.cancel, f.loops = nil, nil, nil
+		}
+	}()
+
+	for _, name := range f.builderNames() {
+		f.schedule(name)
+	}
+	return nil
+}
+
+// Stop stops rebuilding trees, and waits for running builds to finish.
+func (f *forest) Stop() {
+	f.sMu.Lock()
+	if f.ctx != nil {
+		f.cancel()
+		f.ctx, f.cancel, f.loops = nil, nil, nil
+	}
+	f.sMu.Unlock()
+
+	f.wg.Wait()
+}
+
+// schedule start refresh loop of the named tree if started, registered and not running
+func (f *forest) schedule(name string) {
+	f.sMu.Lock()
+	defer f.sMu.Unlock()
+	if f.ctx == nil || f.loops[name] != nil || f.getBuilder(name) == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(f.ctx)
+	f.loops[name] = cancel
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.loop(ctx, name)
+	}()
+}
+
+// restart refresh loop of the named tree to apply new schedule
+func (f *forest) restart(name string) {
+	f.unschedule(name)
+	if f.getBuilder(name) != nil {
+		f.schedule(name)
+	}
+}
+
+// unschedule stop refresh loop of the named tree
+func (f *forest) unschedule(name string) {
+	f.sMu.Lock()
+	defer f.sMu.Unlock()
+	if cancel := f.loops[name]; cancel != nil {
+		cancel()
+		delete(f.loops, name)
+	}
+}
+
+// loop rebuild the named tree on its schedule until ctx done
+func (f *forest) loop(ctx context.Context, name string) {
+	for {
+		s := f.scheduleOf(name)
+		if s.Interval <= 0 {
+			return
+		}
+
+		timer := time.NewTimer(s.next())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			f.RefreshTree(name)
+		}
+	}
+}
+
+func (f *forest) scheduleOf(name string) Schedule {
+	f.sMu.Lock()
+	defer f.sMu.Unlock()
+	if s, ok := f.schedules[name]; ok {
+		return s
+	}
+	return f.fallback
+}
+
+func (f *forest) builderNames() (names []string) {
+	f.bMu.RLock()
+	defer f.bMu.RUnlock()
+	for name := range f.builderM {
+		names = append(names, name)
+	}
+	return names
+}
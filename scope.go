@@ -0,0 +1,145 @@
+package ivy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tr1v3r/ivy/driver"
+)
+
+// scopeCacheSize is the most scoped contents cached per node
+const scopeCacheSize = 1024
+
+// scopedContent content realized for a request scope
+type scopedContent struct {
+	content    []byte
+	realizedAt time.Time
+	gen        uint64
+}
+
+// getScoped works like GetWithContext, but content is realized on base, which is
+// the content realized by parent for the same request, and never stored on the node.
+func (t *tree) getScoped(rc *driver.RealizeContext, base []byte, path string, res *Result) ([]byte, error) {
+	if rc == nil {
+		rc = t.defaultCtx
+	}
+
+	v := t.loadView()
+	content, err := t.realizeScoped(rc, v, base)
+	if err != nil {
+		return nil, fmt.Errorf("realize rule on %s fail: %w", t.Path(), err)
+	}
+
+	if t.driver.GetLevel(path) == t.level {
+		return content, nil
+	}
+
+	name := t.driver.GetNameByLevel(path, t.level+1)
+	if child, kind, param := v.match(name); child != nil {
+		rc, path = t.capture(rc, child, kind, param, name, path)
+		if child, ok := child.(*tree); ok {
+			return child.getScoped(rc, content, path, res)
+		}
+		return res.merge(child.GetResult(rc, path))
+	}
+	return t.doFallback(rc, content)
+}
+
+// realizeScoped realize processors in view v on base without touching the shared content.
+func (t *tree) realizeScoped(rc *driver.RealizeContext, v *view, base []byte) ([]byte, error) {
+	key, cacheable := t.scopeKey(rc)
+	if cacheable {
+		if content, ok := t.loadScoped(key, v.gen); ok {
+			return content, nil
+		}
+	}
+
+	if !t.allow() {
+		return nil, ErrRateLimited
+	}
+
+	content, err := t.driver.Realize(rc.WithNode(t.path, base), base, v.procs...)
+	if err != nil {
+		return nil, fmt.Errorf("realize rule fail: %w", err)
+	}
+
+	if cacheable {
+		t.storeScoped(key, v.gen, content)
+	}
+	return content, nil
+}
+
+// scopeKey build cache key from the values of scope keys in rc.Params.
+// returns false when result should not be cached.
+func (t *tree) scopeKey(rc *driver.RealizeContext) (string, bool) {
+	if len(t.scopeKeys) == 0 || t.instantMode {
+		return "", false
+	}
+	var values = make([]string, len(t.scopeKeys))
+	for i, key := range t.scopeKeys {
+		values[i] = strconv.Quote(rc.Params[key])
+	}
+	return strings.Join(values, ","), true
+}
+
+// loadScoped get content cached for key, realized by processors of gen.
+func (t *tree) loadScoped(key string, gen uint64) ([]byte, bool) {
+	t.scopeMu.RLock()
+	defer t.scopeMu.RUnlock()
+	c, ok := t.scopeCache[key]
+	if !ok || c.gen != gen || (t.cacheTTL > 0 && time.Since(c.realizedAt) >= t.cacheTTL) {
+		return nil, false
+	}
+	return c.content, true
+}
+
+func (t *tree) storeScoped(key string, gen uint64, content []byte) {
+	t.scopeMu.Lock()
+	defer t.scopeMu.Unlock()
+	if t.scopeCache == nil {
+		t.scopeCache = make(map[string]scopedContent)
+	}
+	if _, ok := t.scopeCache[key]; !ok && len(t.scopeCache) >= scopeCacheSize {
+		t.evictScoped(gen)
+	}
+	t.scopeCache[key] = scopedContent{content: content, realizedAt: time.Now(), gen: gen}
+}
+
+// evictScoped drop cached contents expired or realized by processors before gen,
+// and the oldest one if none dropped, must be called with scopeMu held.
+func (t *tree) evictScoped(gen uint64) {
+	var oldest string
+	var oldestAt time.Time
+	for key, c := range t.scopeCache {
+		if c.gen != gen || (t.cacheTTL > 0 && time.Since(c.realizedAt) >= t.cacheTTL) {
+			delete(t.scopeCache, key)
+			continue
+		}
+		if oldestAt.IsZero() || c.realizedAt.Before(oldestAt) {
+			oldest, oldestAt = key, c.realizedAt
+		}
+	}
+	if len(t.scopeCache) >= scopeCacheSize {
+		delete(t.scopeCache, oldest)
+	}
+}
+
+// resetScoped drop scoped cache of node and all subtrees,
+// as children are realized on top of parent content.
+func (t *tree) resetScoped() {
+	if !t.scopedMode {
+		return
+	}
+
+	t.scopeMu.Lock()
+	t.scopeCache = nil
+	t.scopeMu.Unlock()
+
+	for _, child := range t.getChildren() {
+		if child, ok := child.(*tree); ok {
+			child.resetScoped()
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package ivy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+
+	"github.com/tr1v3r/ivy/driver"
+)
+
+// tree modes in spec
+const (
+	// ModeStandard realize all nodes when building, see NewTree
+	ModeStandard = "standard"
+	// ModeLazy realize nodes on first access, see NewLazyTree
+	ModeLazy = "lazy"
+	// ModeInstant realize nodes on every access, see NewLazyInstantTree
+	ModeInstant = "instant"
+	// ModeCache realize nodes on access after TTL expired, see NewLazyCacheTree
+	ModeCache = "cache"
+	// ModeStale serve stale nodes while revalidating, see NewLazyStaleCacheTree
+	ModeStale = "stale"
+	// ModeScoped realize nodes per request, see NewScopedTree
+	ModeScoped = "scoped"
+)
+
+// ForestSpec declares all trees in a forest.
+type ForestSpec struct {
+	// RateLimit is the global rate limit for all GetVal calls
+	RateLimit *RateLimitSpec `json:"rate_limit,omitempty"`
+	// Refresh and Jitter are the default refresh schedule of trees after forest started
+	Refresh Duration `json:"refresh,omitempty"`
+	Jitter  Duration `json:"jitter,omitempty"`
+
+	Trees []TreeSpec `json:"trees"`
+}
+
+// TreeSpec declares a tree.
+type TreeSpec struct {
+	Name string `json:"name"`
+	// Driver is the registered driver name, see driver.RegisterDriver
+	Driver string `json:"driver"`
+	// Mode is one of ModeStandard/ModeLazy/ModeInstant/ModeCache/ModeStale/ModeScoped,
+	// empty means ModeStandard
+	Mode string `json:"mode,omitempty"`
+	// TTL is the cache TTL for ModeCache/ModeStale/ModeScoped, required by them
+	TTL Duration `json:"ttl,omitempty"`
+	// MaxStale bounds stale serving for ModeStale
+	MaxStale Duration `json:"max_stale,omitempty"`
+	// ScopeKeys are the Params keys to cache by for ModeScoped
+	ScopeKeys []string `json:"scope_keys,omitempty"`
+	// Refresh and Jitter are the refresh schedule of the tree after forest started,
+	// the forest default is used when Refresh is zero
+	Refresh Duration `json:"refresh,omitempty"`
+	Jitter  Duration `json:"jitter,omitempty"`
+
+	Template  string         `json:"template"`
+	RateLimit *RateLimitSpec `json:"rate_limit,omitempty"`
+	// Fallback is the processors data for the tree fallback, decoded by driver modem
+	Fallback json.RawMessage `json:"fallback,omitempty"`
+
+	Directives []DirectiveSpec `json:"directives,omitempty"`
+}
+
+// DirectiveSpec declares a directive.
+type DirectiveSpec struct {
+	Path string `json:"path"`
+	// Processors is the processors data, decoded by driver modem
+	Processors json.RawMessage `json:"processors,omitempty"`
+}
+
+// RateLimitSpec declares a rate limit.
+type RateLimitSpec struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Duration is a time.Duration encoded as string like "1m30s" in spec.
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) { return json.Marshal(time.Duration(d).String()) }
+
+// UnmarshalJSON implements json.Unmarshaler, accepts both string and nanoseconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v)
+	case string:
+		dur, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("parse duration fail: %w", err)
+		}
+		*d = Duration(dur)
+	default:
+		return fmt.Errorf("invalid duration: %s", data)
+	}
+	return nil
+}
+
+// LoadForestSpec reads forest spec from file, format is detected by extension.
+func LoadForestSpec(filename string) (*ForestSpec, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read spec file fail: %w", err)
+	}
+	return ParseForestSpec(data, strings.TrimPrefix(filepath.Ext(filename), "."))
+}
+
+// ParseForestSpec parses forest spec in format json, yaml(yml) or toml.
+func ParseForestSpec(data []byte, format string) (*ForestSpec, error) {
+	data, err := normalizeSpec(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec ForestSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("unmarshal spec fail: %w", err)
+	}
+	return &spec, nil
+}
+
+// normalizeSpec convert spec data to json, so processors data can be decoded by driver modem
+func normalizeSpec(data []byte, format string) ([]byte, error) {
+	var v any
+	switch strings.ToLower(format) {
+	case "json":
+		return data, nil
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("unmarshal yaml spec fail: %w", err)
+		}
+	case "toml":
+		if err := toml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("unmarshal toml spec fail: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported spec format: %s", format)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("convert %s spec to json fail: %w", format, err)
+	}
+	return data, nil
+}
+
+// LoadForest builds a forest from spec.
+// Every tree is built once to check the spec, and rebuilt from spec on refresh.
+func LoadForest(spec *ForestSpec) (Forest, error) {
+	f := newForest()
+	f.SetDefaultSchedule(Schedule{Interval: time.Duration(spec.Refresh), Jitter: time.Duration(spec.Jitter)})
+	for i := range spec.Trees {
+		ts := &spec.Trees[i]
+		tree, err := ts.Build()
+		if err != nil {
+			return nil, fmt.Errorf("build tree %s fail: %w", ts.Name, err)
+		}
+
+		if ts.Refresh != 0 {
+			f.SetSchedule(tree.Name(), Schedule{Interval: time.Duration(ts.Refresh), Jitter: time.Duration(ts.Jitter)})
+		}
+		f.RegisterE(tree.Name(), ts.builder())
+		f.Set(tree)
+	}
+	if rl := spec.RateLimit; rl != nil {
+		f.SetRateLimit(rate.Limit(rl.Rate), rl.Burst)
+	}
+	return f, nil
+}
+
+// Build builds the tree declared by spec.
+func (s *TreeSpec) Build() (Tree, error) {
+	d, err := driver.NewDriver(s.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := s.newTree(d)
+	if err != nil {
+		return nil, err
+	}
+
+	directives := make([]Directive, 0, len(s.Directives))
+	for _, ds := range s.Directives {
+		procs, err := unmarshalProcessors(d, ds.Processors)
+		if err != nil {
+			return nil, fmt.Errorf("load processors on %s fail: %w", ds.Path, err)
+		}
+		directives = append(directives, NewDirective(ds.Path, procs...))
+	}
+
+	if rl := s.RateLimit; rl != nil {
+		t.SetRateLimit(rate.Limit(rl.Rate), rl.Burst)
+	}
+
+	tree, err := buildTree(t, directives...)
+	if err != nil {
+		return nil, err
+	}
+
+	fallback, err := unmarshalProcessors(d, s.Fallback)
+	if err != nil {
+		return nil, fmt.Errorf("load fallback processors fail: %w", err)
+	}
+	switch len(fallback) {
+	case 0:
+	case 1:
+		tree.SetFallback(fallback[0])
+	default:
+		tree.SetFallback(driver.CombineProcessor(fallback...))
+	}
+	return tree, nil
+}
+
+// builder return a TreeBuilderE building tree from spec
+func (s *TreeSpec) builder() TreeBuilderE {
+	return func() (Tree, error) {
+		tree, err := s.Build()
+		if err != nil {
+			return nil, fmt.Errorf("build tree %s from spec fail: %w", s.Name, err)
+		}
+		return tree, nil
+	}
+}
+
+// newTree create an empty tree in spec mode
+func (s *TreeSpec) newTree(d driver.Driver) (*tree, error) {
+	t := newTree[Directive](d, s.Name, s.Template)
+	switch s.Mode {
+	case ModeCache, ModeStale, ModeScoped:
+		if s.TTL <= 0 {
+			return nil, fmt.Errorf("tree mode %s requires a positive ttl, got %s", s.Mode, time.Duration(s.TTL))
+		}
+	}
+	switch s.Mode {
+	case "", ModeStandard:
+		return t, nil
+	case ModeLazy:
+		return t.lazy(), nil
+	case ModeInstant:
+		return t.lazy().instant(), nil
+	case ModeCache:
+		return t.lazy().cache(time.Duration(s.TTL)), nil
+	case ModeStale:
+		return t.lazy().cache(time.Duration(s.TTL)).stale(time.Duration(s.MaxStale)), nil
+	case ModeScoped:
+		return t.lazy().cache(time.Duration(s.TTL)).scoped(s.ScopeKeys...), nil
+	default:
+		return nil, fmt.Errorf("unknown tree mode: %s", s.Mode)
+	}
+}
+
+func unmarshalProcessors(d driver.Driver, data json.RawMessage) ([]driver.Processor, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return d.Unmarshal(data)
+}
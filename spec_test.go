@@ -0,0 +1,203 @@
+package ivy_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tr1v3r/ivy"
+	"github.com/tr1v3r/ivy/driver"
+)
+
+func TestLoadForest(t *testing.T) {
+	var specs = map[string]string{
+		"json": `{
+	"trees": [
+		{
+			"name": "users",
+			"driver": "json",
+			"template": "{\"id\":1}",
+			"directives": [
+				{"path": "/", "processors": [
+					{"type": "create", "json_path": "name", "value": "river", "value_type": "string"},
+					{"type": "set", "json_path": "tags", "value": ["a"]}
+				]},
+				{"path": "/a/b", "processors": [{"type": "delete", "json_path": "id"}]}
+			]
+		},
+		{"name": "cached", "driver": "json", "mode": "cache", "ttl": "1m", "template": "{}"}
+	]
+}`,
+		"yaml": `
+trees:
+  - name: users
+    driver: json
+    template: '{"id":1}'
+    directives:
+      - path: /
+        processors:
+          - {type: create, json_path: name, value: river, value_type: string}
+          - {type: set, json_path: tags, value: [a]}
+      - path: /a/b
+        processors:
+          - {type: delete, json_path: id}
+  - {name: cached, driver: json, mode: cache, ttl: 1m, template: '{}'}
+`,
+		"toml": `
+[[trees]]
+name = "users"
+driver = "json"
+template = '{"id":1}'
+
+[[trees.directives]]
+path = "/"
+processors = [
+  {type = "create", json_path = "name", value = "cml2ZXI="}, # base64 without value_type
+  {type = "set", json_path = "tags", value = ["a"]},
+]
+
+[[trees.directives]]
+path = "/a/b"
+processors = [{type = "delete", json_path = "id"}]
+
+[[trees]]
+name = "cached"
+driver = "json"
+mode = "cache"
+ttl = "1m"
+template = '{}'
+`,
+	}
+
+	for format, data := range specs {
+		spec, err := ivy.ParseForestSpec([]byte(data), format)
+		if err != nil {
+			t.Errorf("parse %s spec fail: %s", format, err)
+			continue
+		}
+		f, err := ivy.LoadForest(spec)
+		if err != nil {
+			t.Errorf("load forest from %s spec fail: %s", format, err)
+			continue
+		}
+
+		for path, expected := range map[string]string{
+			"/":    `{"id":1,"name":"river","tags":["a"]}`,
+			"/a/b": `{"name":"river","tags":["a"]}`,
+		} {
+			val, err := f.GetVal("users", path)
+			if err != nil {
+				t.Errorf("[%s] get %s fail: %s", format, path, err)
+				continue
+			}
+			if string(val) != expected {
+				t.Errorf("[%s] get %s expected %s, got %s", format, path, expected, val)
+			}
+		}
+		if f.Get("cached") == nil {
+			t.Errorf("[%s] expected tree cached loaded", format)
+		}
+	}
+}
+
+func TestLoadForest_Patch(t *testing.T) {
+	data := `
+trees:
+  - name: patched
+    driver: json
+    template: '{"id":1,"tags":["a"]}'
+    directives:
+      - path: /
+        processors:
+          - kind: json_patch
+            data: {patch: [{op: add, path: /tags/-, value: b}, {op: test, path: /id, value: 1}]}
+      - path: /a
+        processors:
+          - kind: json_merge_patch
+            data: {patch: {id: null, name: a}}
+`
+	spec, err := ivy.ParseForestSpec([]byte(data), "yaml")
+	if err != nil {
+		t.Errorf("parse spec fail: %s", err)
+		return
+	}
+	f, err := ivy.LoadForest(spec)
+	if err != nil {
+		t.Errorf("load forest fail: %s", err)
+		return
+	}
+
+	for path, expected := range map[string]string{
+		"/":  `{"id":1,"tags":["a","b"]}`,
+		"/a": `{"tags":["a","b"],"name":"a"}`,
+	} {
+		val, err := f.GetVal("patched", path)
+		if err != nil {
+			t.Errorf("get %s fail: %s", path, err)
+			continue
+		}
+		if string(val) != expected {
+			t.Errorf("get %s expected %s, got %s", path, expected, val)
+		}
+	}
+}
+
+func TestLoadForest_Invalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"unknown driver": `{"trees":[{"name":"x","driver":"nope"}]}`,
+		"unknown mode":   `{"trees":[{"name":"x","driver":"json","mode":"nope"}]}`,
+		"cache no ttl":   `{"trees":[{"name":"x","driver":"json","mode":"cache"}]}`,
+		"stale zero ttl": `{"trees":[{"name":"x","driver":"json","mode":"stale","ttl":"0s"}]}`,
+		"scoped no ttl":  `{"trees":[{"name":"x","driver":"json","mode":"scoped","scope_keys":["user"]}]}`,
+	} {
+		spec, err := ivy.ParseForestSpec([]byte(data), "json")
+		if err != nil {
+			t.Errorf("parse spec fail: %s", err)
+			continue
+		}
+		if _, err := ivy.LoadForest(spec); err == nil {
+			t.Errorf("expected load forest with %s to fail", name)
+		}
+	}
+}
+
+func TestTree_ExportImport(t *testing.T) {
+	tree, err := ivy.NewLazyCacheJSONTree("export_test", `{"id":1}`, time.Minute,
+		ivy.NewDirective("/", &driver.JSONProcessor{T: "create", JSONPath: "name", V: []byte("river")}),
+		ivy.NewDirective("/a/b", &driver.JSONProcessor{T: "delete", JSONPath: "id"}),
+		ivy.NewDirective("/a/:id"),
+	)
+	if err != nil {
+		t.Fatalf("build tree fail: %s", err)
+	}
+	tree.SetRateLimit(100, 10)
+	tree.SetFallback(&driver.JSONProcessor{T: "create", JSONPath: "fallback", V: []byte("yes")})
+
+	data, err := tree.Export()
+	if err != nil {
+		t.Fatalf("export tree fail: %s", err)
+	}
+	t.Logf("exported tree: %s", data)
+
+	imported, err := ivy.ImportTree(data)
+	if err != nil {
+		t.Fatalf("import tree fail: %s", err)
+	}
+	if string(imported.ShowStruct()) != string(tree.ShowStruct()) {
+		t.Errorf("expected struct %s, got %s", tree.ShowStruct(), imported.ShowStruct())
+	}
+	for _, path := range []string{"/", "/a", "/a/b", "/a/42", "/x"} {
+		expected, _ := tree.Get(path)
+		got, err := imported.Get(path)
+		if err != nil {
+			t.Errorf("get %s from imported tree fail: %s", path, err)
+			continue
+		}
+		if string(got) != string(expected) {
+			t.Errorf("get %s expected %s, got %s", path, expected, got)
+		}
+	}
+
+	if again, _ := imported.Export(); string(again) != string(data) {
+		t.Errorf("expected export of imported tree identical, got %s", again)
+	}
+}
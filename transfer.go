@@ -0,0 +1,118 @@
+package ivy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Export dumps the tree as TreeSpec in json, including template, mode, driver
+// and directives of every node, processors are saved by driver modem.
+// The result can be rebuilt into an identical tree by ImportTree.
+func (t *tree) Export() ([]byte, error) {
+	spec, err := t.spec()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(spec)
+}
+
+// ImportTree builds a tree from data dumped by Tree.Export.
+func ImportTree(data []byte) (Tree, error) {
+	var spec TreeSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("unmarshal tree spec fail: %w", err)
+	}
+	return spec.Build()
+}
+
+// spec build TreeSpec describing the tree
+func (t *tree) spec() (*TreeSpec, error) {
+	spec := &TreeSpec{
+		Name:     t.name,
+		Driver:   t.driver.Name(),
+		Template: string(t.template),
+	}
+	spec.Mode, spec.TTL, spec.MaxStale, spec.ScopeKeys = t.mode()
+
+	t.rlMu.RLock()
+	if limiter := t.rateLimiter; limiter != nil {
+		spec.RateLimit = &RateLimitSpec{Rate: float64(limiter.Limit()), Burst: limiter.Burst()}
+	}
+	t.rlMu.RUnlock()
+
+	if t.fallback != nil {
+		data, err := t.driver.Marshal(t.fallback)
+		if err != nil {
+			return nil, fmt.Errorf("save fallback fail: %w", err)
+		}
+		spec.Fallback = data
+	}
+
+	if err := t.walk(func(node *tree) error {
+		path, directives := node.nodePath(), node.getDirectives()
+		if len(directives) == 0 { // keep the node without directives
+			spec.Directives = append(spec.Directives, DirectiveSpec{Path: path})
+			return nil
+		}
+		for _, r := range directives {
+			data, err := t.driver.Marshal(r.Processors()...)
+			if err != nil {
+				return fmt.Errorf("save processors on %s fail: %w", path, err)
+			}
+			spec.Directives = append(spec.Directives, DirectiveSpec{Path: path, Processors: data})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// mode return tree mode and its arguments in spec
+func (t *tree) mode() (mode string, ttl, maxStale Duration, keys []string) {
+	ttl, maxStale = Duration(t.cacheTTL), Duration(t.maxStale)
+	switch {
+	case t.scopedMode:
+		return ModeScoped, ttl, 0, t.scopeKeys
+	case t.staleMode:
+		return ModeStale, ttl, maxStale, nil
+	case t.instantMode:
+		return ModeInstant, 0, 0, nil
+	case t.cacheTTL > 0:
+		return ModeCache, ttl, 0, nil
+	case t.lazyMode:
+		return ModeLazy, 0, 0, nil
+	default:
+		return ModeStandard, 0, 0, nil
+	}
+}
+
+// nodePath return node path used in directive
+// root path is the bare delimiter, e.g. "/"
+func (t *tree) nodePath() string {
+	if t.level == 0 {
+		return t.driver.AppendPath("", "")
+	}
+	return t.path
+}
+
+// walk calls fn on the tree and all subtrees in depth-first order, children sorted by name
+func (t *tree) walk(fn func(node *tree) error) error {
+	if err := fn(t); err != nil {
+		return err
+	}
+
+	children := t.getChildren()
+	sort.Slice(children, func(i, j int) bool { return children[i].Name() < children[j].Name() })
+	for _, child := range children {
+		node, ok := child.(*tree)
+		if !ok {
+			return fmt.Errorf("walk on %s fail: unsupported tree type %T", child.Path(), child)
+		}
+		if err := node.walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/tr1v3r/pkg/guard"
+	"github.com/tr1v3r/pkg/log"
 	"golang.org/x/time/rate"
 
 	"github.com/tr1v3r/ivy/driver"
@@ -18,8 +21,14 @@ type tree struct {
 	name string // node name
 	path string // node path
 
+	template []byte // root template
+
 	mu       sync.RWMutex
 	children map[string]Tree
+	// paramChild and wildcardChild are names of the children declared as
+	// ":name" and "*name", used when no literal child matches a segment
+	paramChild    string
+	wildcardChild string
 
 	// for subtree
 	driver driver.Driver
@@ -28,10 +37,16 @@ type tree struct {
 	// current node rule
 	contentMu sync.RWMutex
 	content   []byte
+	// base is the content inherited from parent (template for root) which
+	// processors are realized on, current content is kept until realization succeeds
+	base []byte
 
-	// procs processor array
-	// only set when tree build, only concurrent reads, so mutex is verbose
-	procs []driver.Processor
+	// directives applied on the node in order, procs is their processors flattened.
+	// node content is always realized from base by procs, so the result of an
+	// operation on directives does not depend on what was realized before.
+	// Guarded by realizeMu.
+	directives []Directive
+	procs      []driver.Processor
 
 	// fallback is called when path resolution cannot find a matching child.
 	fallback driver.Processor
@@ -58,10 +73,53 @@ type tree struct {
 	cacheTTL   time.Duration
 	realizeMu  sync.RWMutex
 	realizedAt time.Time
+	// gen counts changes of procs, content realized is served only to readers of
+	// the views of the gen it was realized by. Guarded by realizeMu.
+	gen, realizedGen uint64
+
+	// Stale Mode:
+	// In Stale Mode, expired content keeps being served while one background goroutine
+	// re-realizes it (stale-while-revalidate), so readers never wait on slow processors.
+	// maxStale bounds how long after expiry content may be served stale, beyond which
+	// readers realize synchronously. A zero value means no bound.
+	staleMode    bool
+	maxStale     time.Duration
+	revalidating int32
+
+	// Last Good:
+	// With lastGood policy set, a failed realization keeps the previous content and
+	// serves it as degraded, the error is recorded and realization is retried on a
+	// growing backoff. Guarded by realizeMu.
+	lastGood *LastGoodPolicy
+	failures int
+	lastErr  error
+	retryAt  time.Time
+
+	// Scoped Mode:
+	// In Scoped Mode, content is realized into a per-request buffer and passed
+	// down from parent to child along the request path, the shared node content
+	// is never written back. So one request's Params never leak into another's.
+	// When scopeKeys is set, results are cached by the values of these Params.
+	scopedMode bool
+	scopeKeys  []string
+	scopeMu    sync.RWMutex
+	scopeCache map[string]scopedContent
 
 	rlMu        sync.RWMutex
 	rateLimiter *rate.Limiter
 
+	// observer is notified after every successful change made through the root, set by forest
+	obMu     sync.RWMutex
+	observer func(Tree)
+
+	// view is the latest immutable view of the node for lock-free reads,
+	// published by refresh on every change, viewMu serializes publishing.
+	view   atomic.Pointer[view]
+	viewMu sync.Mutex
+
+	// writeMu serializes writes made through the node
+	writeMu sync.Mutex
+
 	defaultCtx *driver.RealizeContext
 }
 
@@ -80,9 +138,21 @@ func (t *tree) cache(ttl time.Duration) *tree {
 	return t
 }
 
+func (t *tree) stale(maxStale time.Duration) *tree {
+	t.staleMode = true
+	t.maxStale = maxStale
+	return t
+}
+
+func (t *tree) scoped(keys ...string) *tree {
+	t.scopedMode = true
+	t.scopeKeys = keys
+	return t
+}
+
 func (t *tree) build(rules ...Directive) error {
 	for _, r := range byLevel(t.driver, rules) {
-		if err := t.Set(r); err != nil {
+		if err := t.Append(r); err != nil {
 			return fmt.Errorf("apply rule fail: %w", err)
 		}
 	}
@@ -107,14 +177,68 @@ func (t *tree) SetRateLimit(r rate.Limit, burst int) {
 	t.rateLimiter = rate.NewLimiter(r, burst)
 }
 
+// Set replaces the directives on the node at the directive path.
 func (t *tree) Set(r Directive) error {
-	if level := t.driver.GetLevel(r.Path()); t.level == level { // check if level matched, include root node
-		return t.apply(r.Processors()...)
+	return t.write(func() error { return t.update(OpReplace, r) })
+}
+
+// Append appends a directive to the directives on the node at the directive path.
+func (t *tree) Append(r Directive) error {
+	return t.write(func() error { return t.update(OpAppend, r) })
+}
+
+// Remove removes all directives on the node at path.
+func (t *tree) Remove(path string) error {
+	return t.write(func() error { return t.update(OpRemove, NewDirective(path)) })
+}
+
+// update apply op with directive on the node at the directive path
+func (t *tree) update(op DirectiveOp, r Directive) error {
+	level := t.driver.GetLevel(r.Path())
+	if t.level == level { // check if level matched, include root node
+		return t.apply(op, r)
+	}
+
+	name := t.driver.GetNameByLevel(r.Path(), t.level+1)
+	switch kind, _ := driver.ParseSegment(name); kind {
+	case driver.ParamSegment:
+		if param := t.getParamChild(); param != "" && param != name {
+			return fmt.Errorf("param segment %s conflicts with %s on %s", name, param, t.Path())
+		}
+	case driver.WildcardSegment:
+		if level > t.level+1 {
+			return fmt.Errorf("wildcard segment %s must be the last segment of %s", name, r.Path())
+		}
+	}
+
+	var child Tree
+	if op == OpRemove { // never create node on remove
+		if child = t.pickChild(name); child == nil {
+			return fmt.Errorf("remove directives on %s fail: %w", r.Path(), ErrNotExistsNode)
+		}
+	} else {
+		child = t.getChild(name)
+	}
+
+	switch child := child.(type) {
+	case *tree:
+		return child.update(op, r)
+	default:
+		switch op {
+		case OpAppend:
+			return child.Append(r)
+		case OpRemove:
+			return child.Remove(r.Path())
+		default:
+			return child.Set(r)
+		}
 	}
-	return t.getChild(t.driver.GetNameByLevel(r.Path(), t.level+1)).Set(r)
 }
 
-// Get retrieves the rule data at the given path.
+// Get retrieves the rule data at the given path with the default context.
+func (t *tree) Get(path string) ([]byte, error) { return t.GetWithContext(nil, path) }
+
+// GetWithContext retrieves rule data with runtime context for dynamic construction.
 //
 // The tree is organized as a hierarchical structure where each node corresponds to a
 // path level. Get traverses the tree level by level, from root to the target node:
@@ -134,49 +258,95 @@ func (t *tree) Set(r Directive) error {
 // The recursion forms a chain: root → level1 → level2 → ... → target node.
 // Each level realizes its own content before passing control to the next, ensuring
 // the content flows correctly down the tree hierarchy.
-func (t *tree) Get(path string) ([]byte, error) {
+//
+// When a path segment matches no literal child, the param child (":name") or the
+// wildcard child ("*name") is picked instead, and the captured segment values are
+// passed to processors and fallback through RealizeContext.Params.
+func (t *tree) GetWithContext(rc *driver.RealizeContext, path string) ([]byte, error) {
+	res, err := t.GetResult(rc, path)
+	if err != nil {
+		return nil, err
+	}
+	return res.Content, nil
+}
+
+// GetResult retrieves rule data like GetWithContext, along with how it was served.
+func (t *tree) GetResult(rc *driver.RealizeContext, path string) (*Result, error) {
 	if t == nil {
 		return nil, ErrNotExistsTree
 	}
 
-	if err := t.realize(t.procs); err != nil {
+	// nodes are read by views published atomically, no lock is held while realizing
+	var res Result
+	var err error
+	switch {
+	case !t.lazyMode:
+		res.Content, err = t.lookup(rc, path, &res)
+	case t.scopedMode:
+		res.Content, err = t.getScoped(rc, t.get(), path, &res)
+	default:
+		res.Content, err = t.resolve(rc, path, &res)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// resolve realize nodes from t down to the target node and return its content.
+// metadata about how each node was served is collected into res.
+// processors and children are those in the view of the node when reached.
+func (t *tree) resolve(rc *driver.RealizeContext, path string, res *Result) ([]byte, error) {
+	v := t.loadView()
+	content, err := t.realizeWithContext(rc, v, res)
+	if err != nil {
 		return nil, fmt.Errorf("realize rule on %s fail: %w", t.Path(), err)
 	}
 
 	if t.driver.GetLevel(path) == t.level {
-		return t.get(), nil
+		return content, nil
 	}
 
-	if child := t.pickChild(t.driver.GetNameByLevel(path, t.level+1)); child != nil {
+	name := t.driver.GetNameByLevel(path, t.level+1)
+	if child, kind, param := v.match(name); child != nil {
+		rc, path = t.capture(rc, child, kind, param, name, path)
 		if child, ok := child.(*tree); ok {
-			child.inherit(t)
+			child.inherit(content)
+			return child.resolve(rc, path, res)
 		}
-		return child.Get(path)
+		return res.merge(child.GetResult(rc, path))
 	}
-	return t.doFallback(nil, t.get())
+	return t.doFallback(rc, content)
 }
 
-// GetWithContext retrieves rule data with runtime context for dynamic construction.
-func (t *tree) GetWithContext(rc *driver.RealizeContext, path string) ([]byte, error) {
-	if t == nil {
-		return nil, ErrNotExistsTree
-	}
-
-	if err := t.realizeWithContext(rc, t.procs); err != nil {
-		return nil, fmt.Errorf("realize rule on %s fail: %w", t.Path(), err)
+// capture put the segment value matched by a param or wildcard child into rc.
+// wildcard consumes all remaining segments and becomes the target node, so path is cut to it.
+func (t *tree) capture(rc *driver.RealizeContext, child Tree, kind driver.SegmentKind, param, name, path string) (*driver.RealizeContext, string) {
+	switch kind {
+	case driver.ParamSegment:
+		return t.withParam(rc, param, name), path
+	case driver.WildcardSegment:
+		return t.withParam(rc, param, t.restPath(path, t.level+1)), child.Path()
+	default:
+		return rc, path
 	}
+}
 
-	if t.driver.GetLevel(path) == t.level {
-		return t.get(), nil
+// withParam return a copy of rc with captured param, fall back to default context when rc is nil
+func (t *tree) withParam(rc *driver.RealizeContext, key, value string) *driver.RealizeContext {
+	if rc == nil {
+		rc = t.defaultCtx
 	}
+	return rc.WithParam(key, value)
+}
 
-	if child := t.pickChild(t.driver.GetNameByLevel(path, t.level+1)); child != nil {
-		if child, ok := child.(*tree); ok {
-			child.inherit(t)
-		}
-		return child.GetWithContext(rc, path)
+// restPath return path segments from level to the end
+func (t *tree) restPath(path string, level int) string {
+	rest := t.driver.GetNameByLevel(path, level)
+	for l, n := level+1, t.driver.GetLevel(path); l <= n; l++ {
+		rest = t.driver.AppendPath(rest, t.driver.GetNameByLevel(path, l))
 	}
-	return t.doFallback(rc, t.get())
+	return rest
 }
 
 // doFallback calls the fallback processor if set, otherwise returns content unchanged.
@@ -200,6 +370,22 @@ func (t *tree) SetFallback(proc driver.Processor) {
 	}
 }
 
+// SetLastGoodPolicy sets the policy to serve last good content when realization fails,
+// and propagates it to all subtrees. nil disables it.
+func (t *tree) SetLastGoodPolicy(policy *LastGoodPolicy) {
+	t.realizeMu.Lock()
+	t.lastGood = policy
+	t.realizeMu.Unlock()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, child := range t.children {
+		if ct, ok := child.(*tree); ok {
+			ct.SetLastGoodPolicy(policy)
+		}
+	}
+}
+
 // SetDefaultContext sets the default RealizeContext for this tree and all subtrees.
 func (t *tree) SetDefaultContext(rc *driver.RealizeContext) {
 	t.defaultCtx = rc
@@ -212,26 +398,38 @@ func (t *tree) SetDefaultContext(rc *driver.RealizeContext) {
 	}
 }
 
-// inherit set content by parent's content after check mode and realization
-func (t *tree) inherit(parent *tree) {
+// inherit set base by parent's content after check mode and realization
+//
+// Parent's content is held as the base of the next realization instead of
+// overwriting current content, so stale or last good content can still be served.
+func (t *tree) inherit(content []byte) {
 	if t.lazyMode && t.needRealize() {
-		t.set(parent.get())
+		t.setBase(content)
 	}
 }
 
 // Has check if has node in path
 func (t *tree) Has(path string) bool {
+	var kind driver.SegmentKind
+	if t.level > 0 {
+		kind, _ = driver.ParseSegment(t.Name())
+	}
+	if kind == driver.WildcardSegment {
+		return true
+	}
 	if t.driver.GetLevel(path) == t.level { // check level
-		return t.Name() == t.driver.GetNameByLevel(path, t.level)
+		return kind == driver.ParamSegment || t.Name() == t.driver.GetNameByLevel(path, t.level)
 	}
-	if tree := t.pickChild(t.driver.GetNameByLevel(path, t.level+1)); tree != nil {
+	if tree, _, _ := t.matchChild(t.driver.GetNameByLevel(path, t.level+1)); tree != nil {
 		return tree.Has(path)
 	}
 	return false
 }
 
 // Del delete a node from tree.
-func (t *tree) Del(path string) error {
+func (t *tree) Del(path string) error { return t.write(func() error { return t.del(path) }) }
+
+func (t *tree) del(path string) error {
 	if level := t.driver.GetLevel(path); level == 0 {
 		return fmt.Errorf("root node can not be deleted")
 	} else if t.level+1 == level {
@@ -256,10 +454,17 @@ func (t *tree) ShowStruct() []byte {
 
 // deleteNode delete a node from tree.
 func (t *tree) deleteNode(name string) error {
+	defer t.refresh()
+
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	delete(t.children, name)
-
+	switch name {
+	case t.paramChild:
+		t.paramChild = ""
+	case t.wildcardChild:
+		t.wildcardChild = ""
+	}
 	return nil
 }
 
@@ -270,7 +475,7 @@ func (t *tree) getChild(name string) (tree Tree) {
 		return tree
 	}
 	tree = t.newSubTree(name)
-	t.Graft(tree)
+	t.graft(tree)
 	return tree
 }
 
@@ -291,82 +496,396 @@ func (t *tree) pickChild(name string) Tree {
 	return t.children[name]
 }
 
+// matchChild get a child tree matching the segment name.
+// if not found, return nil
+func (t *tree) matchChild(name string) (child Tree, kind driver.SegmentKind, param string) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return matchChild(t.children, t.paramChild, t.wildcardChild, name)
+}
+
+// matchChild get a child in children matching the segment name.
+// literal child is preferred, then param child, then wildcard child.
+func matchChild(children map[string]Tree, paramChild, wildcardChild, name string) (Tree, driver.SegmentKind, string) {
+	if child := children[name]; child != nil {
+		return child, driver.LiteralSegment, ""
+	}
+	for _, special := range []string{paramChild, wildcardChild} {
+		if special == "" {
+			continue
+		}
+		kind, param := driver.ParseSegment(special)
+		return children[special], kind, param
+	}
+	return nil, driver.LiteralSegment, ""
+}
+
+func (t *tree) getParamChild() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.paramChild
+}
+
 // Graft graft a sub tree
 func (t *tree) Graft(child Tree) {
+	_ = t.write(func() error {
+		t.graft(child)
+		return nil
+	})
+}
+
+func (t *tree) graft(child Tree) {
+	defer t.refresh()
+
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	t.children[child.Name()] = child
+	switch kind, _ := driver.ParseSegment(child.Name()); kind {
+	case driver.ParamSegment:
+		t.paramChild = child.Name()
+	case driver.WildcardSegment:
+		t.wildcardChild = child.Name()
+	}
 }
 
 // newSubTree create a new sub tree.
 // name cannot be empty
 func (t *tree) newSubTree(name string) Tree {
-	return &tree{
+	child := &tree{
 		name: name,
 		path: t.driver.AppendPath(t.path, name),
 
 		defaultCtx: t.defaultCtx,
 		fallback:   t.fallback,
+		lastGood:   t.getLastGoodPolicy(),
 
 		driver:      t.driver,
 		lazyMode:    t.lazyMode,
 		instantMode: t.instantMode,
 		cacheTTL:    t.cacheTTL,
+		staleMode:   t.staleMode,
+		maxStale:    t.maxStale,
+		scopedMode:  t.scopedMode,
+		scopeKeys:   t.scopeKeys,
 
 		level:    t.level + 1,
+		base:     t.get(),
 		content:  t.get(),
 		children: make(map[string]Tree),
 	}
+	child.refresh()
+	return child
 }
 
-// updateDirective parse raw rule Processor to tree node.
-func (t *tree) apply(procs ...driver.Processor) error {
+// apply op with directive on the directive list of node, and recompute the node from its base.
+func (t *tree) apply(op DirectiveOp, r Directive) error {
+	directives := t.getDirectives()
+	switch op {
+	case OpReplace:
+		directives = nil
+		fallthrough
+	case OpAppend:
+		// directive without processors only makes sure the node exists
+		if len(r.Processors()) > 0 {
+			directives = append(directives[:len(directives):len(directives)], r)
+		}
+	case OpRemove:
+		directives = nil
+	default:
+		return fmt.Errorf("unknown directive op: %s", op)
+	}
+
 	if t.lazyMode {
-		t.procs = procs
+		t.setDirectives(directives)
+		t.invalidate()
+		t.resetScoped()
+		t.refresh()
 		return nil
 	}
-	return t.realize(procs)
+	return t.rederive(t.getBase(), directives)
 }
 
-func (t *tree) realize(procs []driver.Processor) error {
-	return t.realizeWithContext(t.defaultCtx, procs)
+// rederive recompute node content from base by re-running directives, then re-derive all
+// subtrees from the new content by their stored directives, so an update on a built
+// node reaches its descendants the same way as a fresh build.
+// Nothing is changed if any realization fails.
+func (t *tree) rederive(base []byte, directives []Directive) error {
+	var updates []derivation
+	if err := t.derive(base, directives, &updates); err != nil {
+		return err
+	}
+	for _, u := range updates {
+		u.commit()
+	}
+	return nil
+}
+
+// derivation content derived for a node, to be committed
+type derivation struct {
+	node       *tree
+	base       []byte
+	content    []byte
+	directives []Directive
+}
+
+func (d derivation) commit() {
+	defer d.node.refresh()
+
+	d.node.realizeMu.Lock()
+	defer d.node.realizeMu.Unlock()
+	d.node.directives, d.node.procs = d.directives, flatten(d.directives)
+	d.node.gen++
+	d.node.setBase(d.base)
+	d.node.set(d.content)
+	d.node.realizedAt, d.node.realizedGen = time.Now(), d.node.gen
 }
 
-func (t *tree) realizeWithContext(rc *driver.RealizeContext, procs []driver.Processor) error {
+// derive realize node and all standard mode subtrees, collect results into updates
+func (t *tree) derive(base []byte, directives []Directive, updates *[]derivation) error {
+	content, err := t.driver.Realize(t.defaultCtx.WithNode(t.path, base), base, flatten(directives)...)
+	if err != nil {
+		return fmt.Errorf("realize rule on %s fail: %w", t.Path(), err)
+	}
+	*updates = append(*updates, derivation{node: t, base: base, content: content, directives: directives})
+
+	for _, child := range t.getChildren() {
+		if child, ok := child.(*tree); ok && !child.lazyMode {
+			if err := child.derive(content, child.getDirectives(), updates); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *tree) setDirectives(directives []Directive) {
+	t.realizeMu.Lock()
+	defer t.realizeMu.Unlock()
+	t.directives, t.procs = directives, flatten(directives)
+	t.gen++
+}
+
+func (t *tree) getDirectives() []Directive {
+	t.realizeMu.RLock()
+	defer t.realizeMu.RUnlock()
+	return t.directives
+}
+
+// flatten return processors of directives in order
+func flatten(directives []Directive) (procs []driver.Processor) {
+	for _, r := range directives {
+		procs = append(procs, r.Processors()...)
+	}
+	return procs
+}
+
+// invalidate drop realization of node and all subtrees,
+// so they are realized again from inherited content on next access.
+func (t *tree) invalidate() {
+	t.realizeMu.Lock()
+	t.realizedAt = time.Time{}
+	t.failures, t.lastErr = 0, nil
+	t.realizeMu.Unlock()
+
+	for _, child := range t.getChildren() {
+		if child, ok := child.(*tree); ok {
+			child.invalidate()
+		}
+	}
+}
+
+// realizeWithContext realize node by processors in view v if needed, and return content to serve.
+// realizations under a view are serialized, content realized is stored only if no newer
+// processors are published meanwhile, and is returned to the reader anyway.
+func (t *tree) realizeWithContext(rc *driver.RealizeContext, v *view, res *Result) ([]byte, error) {
 	if rc == nil {
 		rc = t.defaultCtx
 	}
 	// Fast path: read lock 检查是否可以跳过 realization
-	t.realizeMu.RLock()
-	if !t.instantMode && !t.realizedAt.IsZero() && (t.cacheTTL == 0 || time.Since(t.realizedAt) < t.cacheTTL) {
-		t.realizeMu.RUnlock()
-		return nil
+	switch state, age, content, err := t.freshness(v.gen); state {
+	case nodeFresh:
+		return content, nil
+	case nodeStale: // 返回旧内容，由后台 goroutine 重新 realize
+		t.revalidate(v)
+		res.markStale(age)
+		return content, nil
+	case nodeDegraded: // 上次 realize 失败，退避期内返回最后一次成功的内容
+		res.markDegraded(t.Path(), err)
+		return content, nil
 	}
-	t.realizeMu.RUnlock()
 
-	// Slow path: write lock 执行实际 realization
-	t.realizeMu.Lock()
-	defer t.realizeMu.Unlock()
-	// Double-check: 拿到写锁后再次检查，防止多个 goroutine 同时通过 fast path
-	if !t.instantMode && !t.realizedAt.IsZero() && (t.cacheTTL == 0 || time.Since(t.realizedAt) < t.cacheTTL) {
-		return nil
+	// Slow path: 同一 view 下的 realization 串行执行，不持有 realizeMu
+	v.realizing.Lock()
+	defer v.realizing.Unlock()
+	// Double-check: 拿到锁后再次检查，防止多个 goroutine 同时通过 fast path
+	t.realizeMu.RLock()
+	fresh, content := t.isFresh(v.gen), t.get()
+	t.realizeMu.RUnlock()
+	if fresh {
+		return content, nil
 	}
 
 	// 限流仅针对 lazy/instant/cache 模式，标准模式在 build 阶段 realize 不限流
 	if (t.lazyMode || t.instantMode || t.cacheTTL > 0) && !t.allow() {
-		return ErrRateLimited
+		return nil, ErrRateLimited
 	}
 
-	rule, err := t.driver.Realize(rc, t.get(), procs...)
+	before := t.getBase()
+	rule, err := t.driver.Realize(rc.WithNode(t.path, before), before, v.procs...)
+
+	t.realizeMu.Lock()
+	defer t.realizeMu.Unlock()
 	if err != nil {
-		return fmt.Errorf("realize rule fail: %w", err)
+		err = fmt.Errorf("realize rule fail: %w", err)
+		if t.gen == v.gen && t.recordFailure(err) {
+			res.markDegraded(t.Path(), err)
+			return t.get(), nil
+		}
+		return nil, err
 	}
-	t.set(rule)
+	if t.gen == v.gen {
+		t.set(rule)
+		t.realizedAt, t.realizedGen = time.Now(), v.gen
+		t.failures, t.lastErr = 0, nil
+	}
+	return rule, nil
+}
+
+// nodeState realization state of a node
+type nodeState int
+
+const (
+	// nodeRealize content must be realized before serving
+	nodeRealize nodeState = iota
+	// nodeFresh content can be served as is
+	nodeFresh
+	// nodeStale content is expired but can be served while revalidating
+	nodeStale
+	// nodeDegraded last realization failed, last good content is served until retry
+	nodeDegraded
+)
+
+// freshness report realization state of node for readers of gen, with content to serve.
+// age is the time since the last realization, err is the last realization error.
+func (t *tree) freshness(gen uint64) (state nodeState, age time.Duration, content []byte, err error) {
+	t.realizeMu.RLock()
+	defer t.realizeMu.RUnlock()
+	if t.isFresh(gen) {
+		return nodeFresh, 0, t.get(), nil
+	}
+	if t.realizedAt.IsZero() || t.realizedGen != gen {
+		return nodeRealize, 0, nil, nil
+	}
+	if t.lastGood != nil && t.failures > 0 && time.Now().Before(t.retryAt) {
+		return nodeDegraded, 0, t.get(), t.lastErr
+	}
+	if !t.staleMode || t.instantMode {
+		return nodeRealize, 0, nil, nil
+	}
+	if age = time.Since(t.realizedAt); t.maxStale <= 0 || age < t.cacheTTL+t.maxStale {
+		return nodeStale, age, t.get(), nil
+	}
+	return nodeRealize, age, nil, nil
+}
+
+// isFresh check if realization can be skipped for readers of gen, must be called with realizeMu held
+func (t *tree) isFresh(gen uint64) bool {
+	return !t.instantMode && !t.realizedAt.IsZero() && t.realizedGen == gen &&
+		(t.cacheTTL == 0 || time.Since(t.realizedAt) < t.cacheTTL)
+}
+
+// recordFailure record realization error and schedule next retry.
+// returns true if last good content is kept to be served,
+// must be called with realizeMu held.
+func (t *tree) recordFailure(err error) bool {
+	// standard mode realize when building, failure must be reported
+	if t.lastGood == nil || t.realizedAt.IsZero() || !(t.lazyMode || t.instantMode || t.cacheTTL > 0) {
+		return false
+	}
+	t.failures++
+	t.lastErr = err
+	t.retryAt = time.Now().Add(t.lastGood.backoff(t.failures))
+	log.Warn("realize rule on %s fail %d times, serving last good content: %s", t.Path(), t.failures, err)
+	return true
+}
+
+// revalidate realize node by processors in view v in a background goroutine while stale
+// content is served. only one revalidation runs for a node at a time.
+func (t *tree) revalidate(v *view) {
+	if !atomic.CompareAndSwapInt32(&t.revalidating, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&t.revalidating, 0)
+		defer func() {
+			if e := recover(); e != nil {
+				log.Error("revalidate %s panic: %s, stack: %s", t.Path(), e, guard.CatchStack())
+			}
+		}()
+
+		before := t.getBase()
+		rule, err := t.driver.Realize(t.defaultCtx.WithNode(t.path, before), before, v.procs...)
+
+		t.realizeMu.Lock()
+		defer t.realizeMu.Unlock()
+		if t.gen != v.gen { // newer processors published
+			return
+		}
+		if err != nil {
+			if !t.recordFailure(fmt.Errorf("realize rule fail: %w", err)) {
+				log.Warn("revalidate rule on %s fail: %s", t.Path(), err)
+			}
+			return
+		}
+		t.set(rule)
+		t.realizedAt = time.Now()
+		t.failures, t.lastErr = 0, nil
+	}()
+}
+
+// observe set fn to be notified after every successful change made through t
+func (t *tree) observe(fn func(Tree)) {
+	t.obMu.Lock()
+	defer t.obMu.Unlock()
+	t.observer = fn
+}
+
+// write run fn as a write made through t, writes are serialized,
+// observer is notified when fn succeeds.
+func (t *tree) write(fn func() error) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	return t.changed(fn())
+}
 
-	t.realizedAt = time.Now()
+// changed notify observer when err is nil, and return err
+func (t *tree) changed(err error) error {
+	if err != nil {
+		return err
+	}
+	t.obMu.RLock()
+	fn := t.observer
+	t.obMu.RUnlock()
+	if fn != nil {
+		fn(t)
+	}
 	return nil
 }
 
+// setBase set the content processors are realized on
+func (t *tree) setBase(rule []byte) {
+	t.contentMu.Lock()
+	defer t.contentMu.Unlock()
+	t.base = rule
+}
+
+// getBase return the content processors are realized on
+func (t *tree) getBase() []byte {
+	t.contentMu.RLock()
+	defer t.contentMu.RUnlock()
+	return t.base
+}
+
 func (t *tree) set(rule []byte) {
 	t.contentMu.Lock()
 	defer t.contentMu.Unlock()
@@ -380,13 +899,33 @@ func (t *tree) get() (rule []byte) {
 	return t.content
 }
 
+func (t *tree) getLastGoodPolicy() *LastGoodPolicy {
+	t.realizeMu.RLock()
+	defer t.realizeMu.RUnlock()
+	return t.lastGood
+}
+
+// storedContent return content stored on node without realizing it,
+// false if node is lazy and not realized. realized content is never nil.
+func (t *tree) storedContent() ([]byte, bool) {
+	t.realizeMu.RLock()
+	defer t.realizeMu.RUnlock()
+	if t.lazyMode && (t.realizedAt.IsZero() || t.realizedGen != t.gen) {
+		return nil, false
+	}
+	if content := t.get(); content != nil {
+		return content, true
+	}
+	return []byte{}, true
+}
+
 func (t *tree) needRealize() bool {
 	t.realizeMu.RLock()
 	defer t.realizeMu.RUnlock()
 	if t.instantMode {
 		return true
 	}
-	if t.realizedAt.IsZero() {
+	if t.realizedAt.IsZero() || t.realizedGen != t.gen {
 		return true
 	}
 	return t.cacheTTL > 0 && time.Since(t.realizedAt) >= t.cacheTTL
@@ -0,0 +1,92 @@
+package ivy
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tx is a batch of operations applied to a tree atomically by Tree.Apply.
+type Tx struct {
+	ops []txOp
+}
+
+// txOp an operation in transaction, directive op or node deletion
+type txOp struct {
+	del       bool
+	op        DirectiveOp
+	directive Directive
+}
+
+// NewTx create an empty transaction.
+func NewTx() *Tx { return &Tx{} }
+
+// Set adds an operation replacing directives on the node at directive path.
+func (tx *Tx) Set(r Directive) *Tx { return tx.add(txOp{op: OpReplace, directive: r}) }
+
+// Append adds an operation appending directive to the node at directive path.
+func (tx *Tx) Append(r Directive) *Tx { return tx.add(txOp{op: OpAppend, directive: r}) }
+
+// Remove adds an operation removing all directives on the node at path.
+func (tx *Tx) Remove(path string) *Tx {
+	return tx.add(txOp{op: OpRemove, directive: NewDirective(path)})
+}
+
+// Del adds an operation deleting the node at path.
+func (tx *Tx) Del(path string) *Tx { return tx.add(txOp{del: true, directive: NewDirective(path)}) }
+
+// Len return count of operations in transaction
+func (tx *Tx) Len() int { return len(tx.ops) }
+
+func (tx *Tx) add(op txOp) *Tx {
+	tx.ops = append(tx.ops, op)
+	return tx
+}
+
+// Apply applies all operations in tx in order on a copy of the tree, then publishes
+// the copy at once, so concurrent Get sees either none or all of them.
+// The copy is published by swapping the view of t, whose children are all new nodes,
+// so readers take no lock, and lazy nodes realizing by the replaced view never store
+// their content.
+// Nothing is published if any operation fails.
+func (t *tree) Apply(tx *Tx) error {
+	return t.write(func() error {
+		c := t.clone()
+		for i, op := range tx.ops {
+			var err error
+			if op.del {
+				err = c.del(op.directive.Path())
+			} else {
+				err = c.update(op.op, op.directive)
+			}
+			if err != nil {
+				return fmt.Errorf("apply operation %d on %s fail: %w", i, op.directive.Path(), err)
+			}
+		}
+		t.publish(c)
+		return nil
+	})
+}
+
+// publish take over directives, content and children of c, which must not be shared.
+func (t *tree) publish(c *tree) {
+	t.realizeMu.Lock()
+	t.directives, t.procs = c.directives, c.procs
+	t.gen++
+	if c.realizedAt.IsZero() || c.realizedGen != c.gen {
+		t.realizedAt = time.Time{}
+	} else {
+		t.realizedAt, t.realizedGen = c.realizedAt, t.gen
+	}
+	t.failures, t.lastErr = 0, nil
+	t.contentMu.Lock()
+	t.base, t.content = c.base, c.content
+	t.contentMu.Unlock()
+	t.realizeMu.Unlock()
+
+	t.mu.Lock()
+	t.children, t.paramChild, t.wildcardChild = c.children, c.paramChild, c.wildcardChild
+	t.mu.Unlock()
+
+	t.refresh()
+	t.resetScoped()
+}
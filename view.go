@@ -0,0 +1,91 @@
+package ivy
+
+import (
+	"sync"
+
+	"github.com/tr1v3r/ivy/driver"
+)
+
+// view is an immutable view of a node, realized content, processors and children,
+// read without locks. Every change on node publishes a new view instead of modifying it.
+type view struct {
+	content []byte
+
+	// procs are processors of the node in gen, lazy nodes are realized by procs of
+	// the view reached by reader, realizing serializes realizations under the view.
+	procs     []driver.Processor
+	gen       uint64
+	realizing sync.Mutex
+
+	children      map[string]Tree
+	paramChild    string
+	wildcardChild string
+}
+
+// match get a child matching the segment name, see tree.matchChild
+func (v *view) match(name string) (Tree, driver.SegmentKind, string) {
+	return matchChild(v.children, v.paramChild, v.wildcardChild, name)
+}
+
+// refresh publish a new view from current node state, must not be called with realizeMu held.
+// content of lazy nodes in view is not used, as it may not be realized.
+func (t *tree) refresh() {
+	t.viewMu.Lock()
+	defer t.viewMu.Unlock()
+
+	t.realizeMu.RLock()
+	procs, gen := t.procs, t.gen
+	t.realizeMu.RUnlock()
+
+	t.mu.RLock()
+	v := &view{
+		content:       t.get(),
+		procs:         procs,
+		gen:           gen,
+		children:      make(map[string]Tree, len(t.children)),
+		paramChild:    t.paramChild,
+		wildcardChild: t.wildcardChild,
+	}
+	for name, child := range t.children {
+		v.children[name] = child
+	}
+	t.mu.RUnlock()
+
+	t.view.Store(v)
+}
+
+// loadView get the latest view of node, publish one if not yet.
+func (t *tree) loadView() *view {
+	if v := t.view.Load(); v != nil {
+		return v
+	}
+	t.refresh()
+	return t.view.Load()
+}
+
+// lookup get content of the node at path by views from t down, no lock is taken
+// unless a lazy or foreign subtree is reached.
+func (t *tree) lookup(rc *driver.RealizeContext, path string, res *Result) ([]byte, error) {
+	v := t.view.Load()
+	if v == nil { // not published yet
+		return t.resolve(rc, path, res)
+	}
+
+	if t.driver.GetLevel(path) == t.level {
+		return v.content, nil
+	}
+
+	name := t.driver.GetNameByLevel(path, t.level+1)
+	if child, kind, param := v.match(name); child != nil {
+		rc, path = t.capture(rc, child, kind, param, name, path)
+		if child, ok := child.(*tree); ok {
+			if !child.lazyMode {
+				return child.lookup(rc, path, res)
+			}
+			child.inherit(v.content)
+			return child.resolve(rc, path, res)
+		}
+		return res.merge(child.GetResult(rc, path))
+	}
+	return t.doFallback(rc, v.content)
+}
@@ -0,0 +1,218 @@
+package ivy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/tr1v3r/pkg/log"
+
+	"github.com/tr1v3r/ivy/driver"
+)
+
+// watchBufferSize is the channel buffer size of a watcher
+const watchBufferSize = 64
+
+// ChangeEvent is a change on a node of a tree.
+type ChangeEvent struct {
+	Tree string
+	Path string
+	Kind DiffKind
+
+	// OldDigest and NewDigest are sha256 of node content in hex, empty when the
+	// node does not exist before or after the change, or its content was not realized
+	OldDigest, NewDigest string
+	// Patch turns old content into new, only for changed nodes of json trees
+	Patch driver.JSONPatch
+
+	// Version is the tree version with the change
+	Version uint64
+}
+
+// watcher receives events on nodes under prefix of a tree, or of all trees when tree is empty,
+// of versions after since by tree name
+type watcher struct {
+	tree   string
+	prefix string
+	since  map[string]uint64
+	ch     chan ChangeEvent
+}
+
+// Watch returns a channel receiving changes on nodes under pathPrefix of the named tree,
+// or of all trees in forest including those added later when treeName is empty,
+// and a function to stop watching which closes the channel.
+// Changes come from Set, Build and RefreshTree on forest, and changes made through the tree.
+// Events are delivered in version order by a goroutine of forest, apart from writers.
+// Events are dropped when the channel is full, so receivers should keep up.
+// Changes made before stopping are delivered before the channel is closed.
+func (f *forest) Watch(treeName, pathPrefix string) (<-chan ChangeEvent, func()) {
+	w := &watcher{tree: treeName, prefix: pathPrefix, since: make(map[string]uint64),
+		ch: make(chan ChangeEvent, watchBufferSize)}
+
+	// versions still being delivered are not watched
+	f.hMu.RLock()
+	defer f.hMu.RUnlock()
+	for name, h := range f.histories {
+		if treeName == "" || name == treeName {
+			w.since[name] = h.version
+		}
+	}
+
+	f.wMu.Lock()
+	defer f.wMu.Unlock()
+	f.watchers = append(f.watchers, w)
+
+	return w.ch, func() { f.unwatch(w) }
+}
+
+func (f *forest) unwatch(w *watcher) {
+	f.flush()
+
+	f.wMu.Lock()
+	defer f.wMu.Unlock()
+	for i, v := range f.watchers {
+		if v == w {
+			f.watchers = append(f.watchers[:i:i], f.watchers[i+1:]...)
+			close(w.ch)
+			return
+		}
+	}
+}
+
+// watching return watchers on version of tree, must be called with wMu held
+func (f *forest) watching(name string, version uint64) (watchers []*watcher) {
+	for _, w := range f.watchers {
+		if (w.tree == "" || w.tree == name) && version > w.since[name] {
+			watchers = append(watchers, w)
+		}
+	}
+	return watchers
+}
+
+// notification a new version of tree to be notified to watchers
+type notification struct {
+	name       string
+	version    uint64
+	prev, curr *tree
+
+	// flushed is closed when reached instead of notifying, see flush
+	flushed chan struct{}
+}
+
+// enqueue queue n to be delivered, must be called with hMu held so versions are queued in order
+func (f *forest) enqueue(n notification) {
+	f.nMu.Lock()
+	defer f.nMu.Unlock()
+	f.pending = append(f.pending, n)
+	if !f.notifying {
+		f.notifying = true
+		go f.deliver()
+	}
+}
+
+// flush wait until notifications queued before are delivered
+func (f *forest) flush() {
+	n := notification{flushed: make(chan struct{})}
+	f.hMu.Lock()
+	f.enqueue(n)
+	f.hMu.Unlock()
+	<-n.flushed
+}
+
+// deliver notify queued notifications in order until none is left
+func (f *forest) deliver() {
+	for {
+		f.nMu.Lock()
+		if len(f.pending) == 0 {
+			f.notifying = false
+			f.nMu.Unlock()
+			return
+		}
+		n := f.pending[0]
+		f.pending = f.pending[1:]
+		f.nMu.Unlock()
+
+		if n.flushed != nil {
+			close(n.flushed)
+			continue
+		}
+		f.notify(n.name, n.version, n.prev, n.curr)
+	}
+}
+
+// notify send changes between snapshots prev and curr of version to watchers.
+// diff is made on content snapshots realized, no node is realized for it.
+func (f *forest) notify(name string, version uint64, prev, curr *tree) {
+	f.wMu.Lock()
+	watching := len(f.watching(name, version)) > 0
+	f.wMu.Unlock()
+	if !watching {
+		return
+	}
+
+	// avoid typed nil
+	var oldTree, newTree Tree
+	var d driver.Driver
+	if prev != nil {
+		oldTree, d = prev, prev.driver
+	}
+	if curr != nil {
+		newTree, d = curr, curr.driver
+	}
+	if d == nil {
+		return
+	}
+	diff, err := DiffTrees(oldTree, newTree)
+	if err != nil {
+		log.Warn("diff tree %s version %d fail: %s", name, version, err)
+		return
+	}
+
+	// watchers may be stopped while diffing
+	f.wMu.Lock()
+	defer f.wMu.Unlock()
+	watchers := f.watching(name, version)
+	for _, node := range diff.Nodes {
+		event := ChangeEvent{
+			Tree:      name,
+			Path:      node.Path,
+			Kind:      node.Kind,
+			OldDigest: digest(node.Old, node.Kind != NodeAdded),
+			NewDigest: digest(node.New, node.Kind != NodeRemoved),
+			Patch:     node.Patch,
+			Version:   version,
+		}
+		for _, w := range watchers {
+			if !underPath(d, node.Path, w.prefix) {
+				continue
+			}
+			select {
+			case w.ch <- event:
+			default:
+				log.Warn("watcher on %s%s is full, drop change on %s", name, w.prefix, node.Path)
+			}
+		}
+	}
+}
+
+// digest return sha256 of content in hex, empty if not exists or not realized
+func digest(content []byte, exists bool) string {
+	if !exists || content == nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// underPath check if path is prefix or under prefix segment by segment
+func underPath(d driver.Driver, path, prefix string) bool {
+	level := d.GetLevel(prefix)
+	if d.GetLevel(path) < level {
+		return false
+	}
+	for l := 1; l <= level; l++ {
+		if d.GetNameByLevel(path, l) != d.GetNameByLevel(prefix, l) {
+			return false
+		}
+	}
+	return true
+}
@@ -50,7 +50,28 @@ func Serve(timeout time.Duration, handler http.Handler) {
 
 func InitForest(builders ...ivy.TreeBuilder) { f = ivy.NewForest(builders...) }
 
-func RefreshForest() { f = f.Build() }
+func SetForest(forest ivy.Forest) { f = forest }
+
+// RefreshForest rebuild all trees, and logs what changed in each tree
+func RefreshForest() {
+	var olds = make(map[string]ivy.Tree)
+	for _, name := range f.Names() {
+		olds[name] = f.Get(name)
+	}
+
+	f = f.Build()
+
+	for _, name := range f.Names() {
+		diff, err := ivy.DiffTrees(olds[name], f.Get(name))
+		if err != nil {
+			log.Warn("diff tree %s fail: %s", name, err)
+			continue
+		}
+		if !diff.Empty() {
+			log.Info("tree %s changed:\n%s", name, diff)
+		}
+	}
+}
 
 func DefaultBuilder(directives ...ivy.Directive) ivy.TreeBuilder {
 	return func() ivy.Tree {